@@ -2,20 +2,25 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"math/bits"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// reach has a bit set for every summit (see summits) this location can get
+// to so its count is the score (nil once calculateTrails is done with it,
+// except on trailheads), rating is the number of distinct trails from here
+// to any summit
 type location struct {
-	row               int
-	col               int
-	value             int
-	visited           bool
-	visitedFromParent map[string]struct{}
-	score             int
-	seen              int
+	row    int
+	col    int
+	value  int
+	reach  []uint64
+	rating int
 }
 
 type directionMap struct {
@@ -24,9 +29,32 @@ type directionMap struct {
 	label     string
 }
 
+const (
+	// Anything on the map that isn't a digit, trails can't start, end or go
+	// through these
+	IMPASSABLE = -1
+	MAX_HEIGHT = 9
+)
+
 var grid [][]*location
 var trailHeads []*location
 
+// Every location grouped by its height, the DP works back along these
+var levels [MAX_HEIGHT + 1][]*location
+var summits []*location
+
+var (
+	reportFlag   = flag.Bool("report", false, "print the score and rating of every trailhead")
+	trailsFlag   = flag.String("trails", "", "print every distinct trail from the trailhead at row,col")
+	startFlag    = flag.Int("start", 0, "height trails start at")
+	endFlag      = flag.Int("end", MAX_HEIGHT, "height trails end at, trails go down if it's below -start")
+	stepFlag     = flag.Int("step", 1, "how much the height changes with every step")
+	diagonalFlag = flag.Bool("diagonal", false, "allow trails to move diagonally")
+	// How much the height changes each step, negative for trails going down
+	heightStep int
+	moves      []directionMap
+)
+
 var directions = [...]directionMap{
 	{0, 1, "right"},
 	{1, 0, "down"},
@@ -34,6 +62,13 @@ var directions = [...]directionMap{
 	{-1, 0, "up"},
 }
 
+var diagonals = [...]directionMap{
+	{1, 1, "down right"},
+	{1, -1, "down left"},
+	{-1, -1, "up left"},
+	{-1, 1, "up right"},
+}
+
 func init() {
 	scanner := bufio.NewScanner(os.Stdin)
 	rowCount := 0
@@ -42,17 +77,13 @@ func init() {
 		result := []*location{}
 		for i, char := range line {
 			location := &location{
-				row:               rowCount,
-				col:               i,
-				value:             aToIIgnoreError(string(char)),
-				visited:           false,
-				visitedFromParent: make(map[string]struct{}),
-				score:             0,
-				seen:              0,
+				row:   rowCount,
+				col:   i,
+				value: IMPASSABLE,
 			}
-			if char == '0' {
-				location.seen++
-				trailHeads = append(trailHeads, location)
+			if char >= '0' && char <= '9' {
+				location.value = aToIIgnoreError(string(char))
+				levels[location.value] = append(levels[location.value], location)
 			}
 
 			result = append(result, location)
@@ -62,6 +93,33 @@ func init() {
 	}
 }
 
+// Checks the trail rules from the flags and picks out the trailheads and
+// summits they lead to
+func configureTrails() error {
+	start, end, step := *startFlag, *endFlag, *stepFlag
+	if start < 0 || start > MAX_HEIGHT || end < 0 || end > MAX_HEIGHT {
+		return fmt.Errorf("heights must be between 0 and %d", MAX_HEIGHT)
+	}
+	if step < 1 {
+		return fmt.Errorf("invalid step %d", step)
+	}
+	if (end-start)%step != 0 {
+		return fmt.Errorf("no trail gets from %d to %d in steps of %d", start, end, step)
+	}
+
+	heightStep = step
+	if end < start {
+		heightStep = -step
+	}
+	moves = directions[:]
+	if *diagonalFlag {
+		moves = append(moves, diagonals[:]...)
+	}
+	trailHeads = levels[start]
+	summits = levels[end]
+	return nil
+}
+
 func onGrid(row, col int, g [][]*location) bool {
 	return row > -1 && col > -1 && row < len(g) && col < len(g[row])
 }
@@ -71,63 +129,135 @@ func aToIIgnoreError(s string) int {
 	return result
 }
 
+// The locations a trail can go to next from l
 func (l *location) getNext() []*location {
 	result := []*location{}
-	for _, direction := range directions {
+	for _, direction := range moves {
 		rowOffset := l.row + direction.rowOffset
 		colOffset := l.col + direction.colOffset
 		if onGrid(rowOffset, colOffset, grid) {
 			candidate := grid[rowOffset][colOffset]
-			if l.value+1 == candidate.value {
-				// We add the previous nodes seen as this is the number of distinct ways to get to this node
-				candidate.seen += l.seen
-				if !candidate.visited {
-					candidate.visited = true
-					result = append(result, candidate)
-				}
+			if candidate.value != IMPASSABLE && l.value+heightStep == candidate.value {
+				result = append(result, candidate)
 			}
 		}
 	}
 	return result
 }
 
-func resetGrid(grid [][]*location) {
-	for _, row := range grid {
-		for _, loc := range row {
-			loc.visited = false
-			// 0s are head nodes so seen will always be 1 no need to reset
-			if loc.value != 0 {
-				loc.seen = 0
+func (l *location) score() int {
+	count := 0
+	for _, word := range l.reach {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// Works back from the summits one height at a time so everything a location
+// can step to is done before it. A summit reaches itself along one trail,
+// anything lower reaches whatever its next steps reach and has the sum of
+// their trails. One pass covers every trailhead at once
+// e.g. for a 7 with two 8s next to it, one on 2 trails to summit 0 and the
+// other on 1 trail to summits 0 and 1
+// reach = {0} | {0, 1} = {0, 1} so score 2
+// rating = 2 + 1 = 3
+// Each level only reads the reach of the one after it so that's dropped once
+// it's been used, only the trailheads still have theirs at the end
+func calculateTrails() {
+	words := (len(summits) + 63) / 64
+	for i, summit := range summits {
+		summit.reach = make([]uint64, words)
+		summit.reach[i/64] |= 1 << (i % 64)
+		summit.rating = 1
+	}
+	if *startFlag == *endFlag {
+		return
+	}
+	for height := *endFlag - heightStep; ; height -= heightStep {
+		for _, loc := range levels[height] {
+			for _, next := range loc.getNext() {
+				if next.reach == nil {
+					continue
+				}
+				// Dead ends never reach a summit so they never need one
+				if loc.reach == nil {
+					loc.reach = make([]uint64, words)
+				}
+				for i, word := range next.reach {
+					loc.reach[i] |= word
+				}
+				loc.rating += next.rating
+			}
+		}
+		for _, loc := range levels[height+heightStep] {
+			loc.reach = nil
+		}
+		if height == *startFlag {
+			return
+		}
+	}
+}
+
+// Every trail from head as the coordinates along it, only steps onto
+// locations that still have a trail to a summit
+func listTrails(head *location) [][]*location {
+	result := [][]*location{}
+	var walk func(curr *location, trail []*location)
+	walk = func(curr *location, trail []*location) {
+		trail = append(trail, curr)
+		if curr.value == *endFlag {
+			result = append(result, append([]*location{}, trail...))
+			return
+		}
+		for _, next := range curr.getNext() {
+			if next.rating > 0 {
+				walk(next, trail)
 			}
 		}
 	}
+	if head.rating > 0 {
+		walk(head, []*location{})
+	}
+	return result
+}
+
+// Finds the trailhead from a row,col spec
+func findTrailHead(spec string) (*location, error) {
+	var row, col int
+	if _, err := fmt.Sscanf(spec, "%d,%d", &row, &col); err != nil {
+		return nil, fmt.Errorf("invalid trailhead %q, expected row,col", spec)
+	}
+	if !onGrid(row, col, grid) || grid[row][col].value != *startFlag {
+		return nil, fmt.Errorf("[%d, %d] is not a trailhead", row, col)
+	}
+	return grid[row][col], nil
+}
+
+func printTrails(head *location) {
+	trails := listTrails(head)
+	for _, trail := range trails {
+		steps := []string{}
+		for _, loc := range trail {
+			steps = append(steps, fmt.Sprintf("[%d, %d]", loc.row, loc.col))
+		}
+		fmt.Println(strings.Join(steps, " -> "))
+	}
+	fmt.Printf("%d trails from [%d, %d]\n", len(trails), head.row, head.col)
 }
 
 func bothParts() (int, int) {
 	partOne := 0
 	partTwo := 0
 
-	// simple BFS
+	calculateTrails()
 	for _, head := range trailHeads {
-		queue := []*location{head}
-		count := 0
-		for len(queue) > 0 {
-			curr := queue[0]
-			curr.visited = true
-			queue = queue[1:]
-			if curr.value == 9 {
-				// Part one cares about the score of the 'head'
-				head.score += 1
-				// part two cares about distinct ways to reach values of '9'
-				partTwo += curr.seen
-				continue
-			}
-			queue = append(queue, curr.getNext()...)
-			count++
+		// Part one cares about the score of the 'head'
+		partOne += head.score()
+		// part two cares about distinct ways to reach values of '9'
+		partTwo += head.rating
+		if *reportFlag {
+			fmt.Printf("Trailhead [%d, %d]: score %d rating %d\n", head.row, head.col, head.score(), head.rating)
 		}
-
-		partOne += head.score
-		resetGrid(grid)
 	}
 
 	return partOne, partTwo
@@ -141,8 +271,22 @@ func timer() func() {
 }
 
 func main() {
+	flag.Parse()
+	if err := configureTrails(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	defer timer()()
 	partOne, partTwo := bothParts()
 	fmt.Println("Part One:", partOne)
 	fmt.Println("Part Two:", partTwo)
+
+	if *trailsFlag != "" {
+		head, err := findTrailHead(*trailsFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		printTrails(head)
+	}
 }
@@ -2,21 +2,45 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 )
 
-type stoneKey struct {
-	number int
-	blinks int
+// Counts double every few blinks so after a few hundred they no longer fit
+// in an int, small is used until adding would overflow and big after that
+type stoneCount struct {
+	small int
+	big   *big.Int
 }
 
-var cachedResults = make(map[stoneKey]int)
+// On each blink a stone changes by the first rule that matches it, a stone
+// no rule matches stays as it is
+type rule struct {
+	name      string
+	matches   func(number int) bool
+	transform func(number int) []int
+}
+
+const DEFAULT_RULES = "zero,split:2,multiply:2024"
+
 var initialStoneState []int
 
+var (
+	blinksFlag   = flag.Int("blinks", 0, "also count the stones after this many blinks")
+	distinctFlag = flag.Bool("distinct", false, "print how many different numbers are engraved after each blink of the longest run")
+	rulesFlag    = flag.String("rules", DEFAULT_RULES, "ordered rules: zero, split:N for N equal digit groups, multiply:M")
+	rules        []rule
+	// What each number turns into under the current rules, every stone with
+	// the same number changes the same way so it only needs working out once
+	transforms = map[int][]int{}
+)
+
 func init() {
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
@@ -40,61 +64,196 @@ func timer() func() {
 	}
 }
 
-func countAfterBlinking(stoneNumber int, blinks int) int {
-	if count, ok := cachedResults[stoneKey{number: stoneNumber, blinks: blinks}]; ok {
-		return count
+func (s stoneCount) toBig() *big.Int {
+	if s.big != nil {
+		return s.big
 	}
+	return big.NewInt(int64(s.small))
+}
 
-	if blinks == 0 {
-		// no blinks so we have exactly one copy of this stone
-		return 1
+func (s stoneCount) add(o stoneCount) stoneCount {
+	if s.big == nil && o.big == nil && s.small <= math.MaxInt-o.small {
+		return stoneCount{small: s.small + o.small}
 	}
+	return stoneCount{big: new(big.Int).Add(s.toBig(), o.toBig())}
+}
+
+// Adds o in place. Only safe when s.big was made by an earlier accumulate
+// into s, which is true for the counts in the map a blink is building as
+// they all start from zero
+func (s *stoneCount) accumulate(o stoneCount) {
+	if s.big != nil {
+		s.big.Add(s.big, o.toBig())
+		return
+	}
+	*s = s.add(o)
+}
 
-	// if stone is 0, its replaced with stone 1 so get the count of all previous 1s
-	if stoneNumber == 0 {
-		return countAfterBlinking(1, blinks-1)
+func (s stoneCount) String() string {
+	if s.big != nil {
+		return s.big.String()
 	}
+	return strconv.Itoa(s.small)
+}
 
-	stoneAsString := strconv.Itoa(stoneNumber)
-	if len(stoneAsString)%2 == 0 {
-		leftHalf := aToIIgnoreError(stoneAsString[len(stoneAsString)/2:])
-		rightHalf := aToIIgnoreError(stoneAsString[:len(stoneAsString)/2])
-		newStonesCreated := countAfterBlinking(leftHalf, blinks-1) + countAfterBlinking(rightHalf, blinks-1)
-		cachedResults[stoneKey{number: stoneNumber, blinks: blinks}] = newStonesCreated
-		return newStonesCreated
+func countDigits(n int) int {
+	digits := 1
+	for n >= 10 {
+		n /= 10
+		digits++
 	}
+	return digits
+}
 
-	// StoneId Must be odd in length so only one rule left to apply
+// Splits a number into parts groups of the same number of digits without
+// going through a string, 253000 in 2 parts is 253 and 0 (leading zeros just
+// disappear), in 3 parts it's 25, 30 and 0
+func splitDigits(n, parts int) []int {
+	size := 1
+	for range countDigits(n) / parts {
+		size *= 10
+	}
+	result := make([]int, parts)
+	for i := parts - 1; i >= 0; i-- {
+		result[i] = n % size
+		n /= size
+	}
+	return result
+}
 
-	newStonesCreated := countAfterBlinking(stoneNumber*2024, blinks-1)
-	cachedResults[stoneKey{number: stoneNumber, blinks: blinks}] = newStonesCreated
+// Turns a spec like zero,split:2,multiply:2024 into rules, in that order
+//
+//	zero        0 becomes 1
+//	split:N     a number whose digit count divides by N becomes N stones
+//	multiply:M  any number becomes itself * M
+func parseRules(spec string) ([]rule, error) {
+	result := []rule{}
+	for _, part := range strings.Split(spec, ",") {
+		name, arg, hasArg := strings.Cut(strings.TrimSpace(part), ":")
+		value, err := strconv.Atoi(arg)
+		if hasArg && (err != nil || value < 1) {
+			return nil, fmt.Errorf("invalid rule %q", part)
+		}
 
-	return newStonesCreated
+		switch {
+		case name == "zero" && !hasArg:
+			result = append(result, rule{
+				name:      part,
+				matches:   func(number int) bool { return number == 0 },
+				transform: func(int) []int { return []int{1} },
+			})
+		case name == "split" && hasArg && value > 1:
+			parts := value
+			result = append(result, rule{
+				name:      part,
+				matches:   func(number int) bool { return countDigits(number)%parts == 0 },
+				transform: func(number int) []int { return splitDigits(number, parts) },
+			})
+		case name == "multiply" && hasArg:
+			multiplier := value
+			result = append(result, rule{
+				name:    part,
+				matches: func(int) bool { return true },
+				transform: func(number int) []int {
+					if number > math.MaxInt/multiplier {
+						fmt.Fprintf(os.Stderr, "stone %d is too big to multiply by %d\n", number, multiplier)
+						os.Exit(1)
+					}
+					return []int{number * multiplier}
+				},
+			})
+		default:
+			return nil, fmt.Errorf("invalid rule %q", part)
+		}
+	}
+	return result, nil
+}
 
+func transform(number int) []int {
+	if next, ok := transforms[number]; ok {
+		return next
+	}
+	next := []int{number}
+	for _, r := range rules {
+		if r.matches(number) {
+			next = r.transform(number)
+			break
+		}
+	}
+	transforms[number] = next
+	return next
 }
 
-func partOne() int {
-	result := 0
+// Every stone with the same number does exactly the same thing when we blink
+// and the order never matters for the count, so rather than follow each
+// stone this tracks how many stones there are of each number. After a blink
+// every number's count moves to whatever it turns into
+func blink(stones map[int]stoneCount) map[int]stoneCount {
+	next := make(map[int]stoneCount, len(stones))
+	add := func(number int, count stoneCount) {
+		c := next[number]
+		c.accumulate(count)
+		next[number] = c
+	}
+	for number, count := range stones {
+		for _, stone := range transform(number) {
+			add(stone, count)
+		}
+	}
+	return next
+}
 
+// Blinks the given number of times, calling visit after every blink with the
+// stones as they are then
+func countAfterBlinking(blinks int, visit func(blink int, stones map[int]stoneCount)) stoneCount {
+	stones := map[int]stoneCount{}
 	for _, stone := range initialStoneState {
-		result += countAfterBlinking(stone, 25)
+		stones[stone] = stones[stone].add(stoneCount{small: 1})
+	}
+	for i := 1; i <= blinks; i++ {
+		stones = blink(stones)
+		if visit != nil {
+			visit(i, stones)
+		}
 	}
 
-	return result
+	total := stoneCount{}
+	for _, count := range stones {
+		total = total.add(count)
+	}
+	return total
 }
 
-func partTwo() int {
-	result := 0
+func printDistinct(blink int, stones map[int]stoneCount) {
+	fmt.Printf("Blink %d: %d distinct\n", blink, len(stones))
+}
 
-	for _, stone := range initialStoneState {
-		result += countAfterBlinking(stone, 75)
-	}
+func partOne() stoneCount {
+	return countAfterBlinking(25, nil)
+}
 
-	return result
+func partTwo() stoneCount {
+	if *distinctFlag && *blinksFlag == 0 {
+		return countAfterBlinking(75, printDistinct)
+	}
+	return countAfterBlinking(75, nil)
 }
 
 func main() {
+	flag.Parse()
+	var err error
+	if rules, err = parseRules(*rulesFlag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	defer timer()()
 	fmt.Println("Part One:", partOne())
 	fmt.Println("Part Two:", partTwo())
+	if *blinksFlag > 0 {
+		var visit func(int, map[int]stoneCount)
+		if *distinctFlag {
+			visit = printDistinct
+		}
+		fmt.Printf("Blinks %d: %v\n", *blinksFlag, countAfterBlinking(*blinksFlag, visit))
+	}
 }
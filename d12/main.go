@@ -2,14 +2,25 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var grid [][]*location
 var regions []region
 
+var (
+	reportFlag = flag.Bool("report", false, "print the plant, area, perimeter, sides and bounding box of every region")
+	labelsFlag = flag.Bool("labels", false, "print the garden with every plot replaced by its region's id")
+	jsonFlag   = flag.String("json", "", "file to write the regions and both answers to as JSON")
+)
+
 type location struct {
 	row       int
 	col       int
@@ -17,15 +28,46 @@ type location struct {
 	perimeter int
 	visited   bool
 	corners   int
+	region    int
 }
 
+// id is the region's index in regions, the bounding box is inclusive
 type region struct {
+	id        int
 	value     string
 	locations []*location
 	perimeter int
 	area      int
 	price     int
 	edges     int
+	minRow    int
+	minCol    int
+	maxRow    int
+	maxCol    int
+}
+
+type boundingBoxJSON struct {
+	MinRow int `json:"minRow"`
+	MinCol int `json:"minCol"`
+	MaxRow int `json:"maxRow"`
+	MaxCol int `json:"maxCol"`
+}
+
+type regionJSON struct {
+	ID            int             `json:"id"`
+	Plant         string          `json:"plant"`
+	Area          int             `json:"area"`
+	Perimeter     int             `json:"perimeter"`
+	Sides         int             `json:"sides"`
+	Price         int             `json:"price"`
+	DiscountPrice int             `json:"discountPrice"`
+	BoundingBox   boundingBoxJSON `json:"boundingBox"`
+}
+
+type gardenJSON struct {
+	PartOne int          `json:"partOne"`
+	PartTwo int          `json:"partTwo"`
+	Regions []regionJSON `json:"regions"`
 }
 
 type directionMap struct {
@@ -42,7 +84,14 @@ var directions = [...]directionMap{
 }
 
 func init() {
-	scanner := bufio.NewScanner(os.Stdin)
+	loadGarden(os.Stdin)
+}
+
+// Replaces the garden with the map read from r
+func loadGarden(r io.Reader) {
+	grid = nil
+	regions = nil
+	scanner := bufio.NewScanner(r)
 	rowCount := 0
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -62,88 +111,59 @@ func init() {
 	}
 }
 
-func (l *location) calcCorners() {
-	// top left edge of grid
-	if l.row == 0 && l.col == 0 {
-		l.corners++
-	}
-
-	// top right edge of grid
-	if l.row == 0 && l.col == len(grid)-1 {
-		l.corners++
-	}
-
-	// bottom left edge of grid
-	if l.row == len(grid[0])-1 && l.col == len(grid)-1 {
-		l.corners++
-	}
-
-	// bottom right edge of grid
-	if l.row == len(grid[0])-1 && l.col == 0 {
-		l.corners++
-	}
-
-	// Now the messy corner checking... lets take [2,2] as an example for all the below
-	//
-	//	[1,1][1,2][1.2]
-	//	[2,1][2,2][2,3]
-	//	[3.1][3,2][3,3]
-	//
-
-	// Top left outside corner
-	// this would check (and boundary checks)
-	// ([2,1] != l.value && [1,2] != l.value ) || ([2,1] != l.value) || ([1,2] != l.value)
-	// means that in [2, 2] is an outside left corner
-	// and outside corner is an outside corner if X and Y != A where A is the regionID
-	// .Y.
-	// XAA
-	// .AA
-	if (l.col > 0 && l.row > 0 && grid[l.row][l.col-1].value != l.value && grid[l.row-1][l.col].value != l.value) || (l.col > 0 && l.row == 0 && grid[l.row][l.col-1].value != l.value) || (l.col == 0 && l.row > 0 && grid[l.row-1][l.col].value != l.value) {
-		l.corners++
-	}
-
-	// Top left inside corner
-	// this would check (and boundary checks)
-	// [2,3] == l.value && [3.2] == l.value && [3,3] != l.value
-	// means that in [2, 2] is an inside corner
-	// and inside corner is an inside corner if X != A where A is the regionID
-	// AAA
-	// AAA
-	// AAX
-	if l.col < len(grid[0])-1 && l.row < len(grid)-1 && grid[l.row][l.col+1].value == l.value && grid[l.row+1][l.col].value == l.value && grid[l.row+1][l.col+1].value != l.value {
-		l.corners++
-	}
-
-	//bottom right outside
-	if (l.col < len(grid[0])-1 && l.row < len(grid)-1 && grid[l.row][l.col+1].value != l.value && grid[l.row+1][l.col].value != l.value) || (l.col < len(grid[0])-1 && l.row == len(grid)-1 && grid[l.row][l.col+1].value != l.value) || (l.col == len(grid[0])-1 && l.row < len(grid)-1 && grid[l.row+1][l.col].value != l.value) {
-		l.corners++
-	}
-
-	//bottom right inside
-	if l.col > 0 && l.row > 0 && grid[l.row][l.col-1].value == l.value && grid[l.row-1][l.col].value == l.value && grid[l.row-1][l.col-1].value != l.value {
-		l.corners++
-	}
-
-	// bottom left outside
-	if (l.col > 0 && l.row < len(grid)-1 && grid[l.row][l.col-1].value != l.value && grid[l.row+1][l.col].value != l.value) || (l.col > 0 && l.row == len(grid)-1 && grid[l.row][l.col-1].value != l.value) || (l.col == 0 && l.row < len(grid)-1 && grid[l.row+1][l.col].value != l.value) {
-		l.corners++
-	}
-
-	// bottom left inside
-	if l.col < len(grid[0])-1 && l.row > 0 && grid[l.row][l.col+1].value == l.value && grid[l.row-1][l.col].value == l.value && grid[l.row-1][l.col+1].value != l.value {
-		l.corners++
-	}
+// The 8 neighbours of a plot in the order their bits sit in a neighbour mask,
+// starting up and going clockwise
+//
+//	[7][0][1]
+//	[6][X][2]
+//	[5][4][3]
+var neighbourOffsets = [8][2]int{
+	{-1, 0}, {-1, 1}, {0, 1}, {1, 1}, {1, 0}, {1, -1}, {0, -1}, {-1, -1},
+}
 
-	// top right outside
-	if (l.col < len(grid[0])-1 && l.row > 0 && grid[l.row][l.col+1].value != l.value && grid[l.row-1][l.col].value != l.value) || (l.col < len(grid[0])-1 && l.row == 0 && grid[l.row][l.col+1].value != l.value) || (l.col == len(grid[0])-1 && l.row > 0 && grid[l.row-1][l.col].value != l.value) {
-		l.corners++
+// A region has as many sides as it has corners so for every possible mask of
+// which neighbours are in the same region this is how many corners the plot
+// in the middle has. Each diagonal is checked with the two plots either side
+// of it, taking the top right as an example (A is our region)
+// outside corner, neither side is A
+// .X.
+// .AX
+// ...
+// inside corner, both sides are A but the diagonal isn't
+// .AX
+// .AA
+// ...
+var cornerTable = func() [256]int {
+	table := [256]int{}
+	for mask := range 256 {
+		for diagonal := 1; diagonal < 8; diagonal += 2 {
+			before := mask&(1<<(diagonal-1)) != 0
+			after := mask&(1<<((diagonal+1)%8)) != 0
+			across := mask&(1<<diagonal) != 0
+			if (!before && !after) || (before && after && !across) {
+				table[mask]++
+			}
+		}
 	}
-
-	//top right inside
-	if l.col > 0 && l.row < len(grid)-1 && grid[l.row][l.col-1].value == l.value && grid[l.row+1][l.col].value == l.value && grid[l.row+1][l.col-1].value != l.value {
-		l.corners++
+	return table
+}()
+
+// Which of the 8 neighbours are in the same region, anything off the grid
+// isn't. Comparing regions rather than plants handles holes since an enclave
+// of the same plant that isn't connected is still a different region
+func (l *location) neighbourMask() int {
+	mask := 0
+	for bit, offset := range neighbourOffsets {
+		row, col := l.row+offset[0], l.col+offset[1]
+		if onGrid(row, col, grid) && grid[row][col].region == l.region {
+			mask |= 1 << bit
+		}
 	}
+	return mask
+}
 
+func (l *location) calcCorners() {
+	l.corners = cornerTable[l.neighbourMask()]
 }
 
 func (l *location) getNext() []*location {
@@ -186,17 +206,27 @@ func partOne() int {
 			queue := []*location{loc}
 
 			currentRegion := region{
+				id:        len(regions),
 				value:     string(loc.value),
 				locations: []*location{},
 				perimeter: 0,
 				area:      0,
 				price:     0,
 				edges:     0,
+				minRow:    loc.row,
+				minCol:    loc.col,
+				maxRow:    loc.row,
+				maxCol:    loc.col,
 			}
 
 			for len(queue) > 0 {
 				curr := queue[0]
 				curr.visited = true
+				curr.region = currentRegion.id
+				currentRegion.minRow = min(currentRegion.minRow, curr.row)
+				currentRegion.minCol = min(currentRegion.minCol, curr.col)
+				currentRegion.maxRow = max(currentRegion.maxRow, curr.row)
+				currentRegion.maxCol = max(currentRegion.maxCol, curr.col)
 				currentRegion.locations = append(currentRegion.locations, curr)
 				queue = queue[1:]
 				queue = append(queue, curr.getNext()...)
@@ -215,7 +245,8 @@ func partOne() int {
 
 func partTwo() int {
 	result := 0
-	for _, region := range regions {
+	for i := range regions {
+		region := &regions[i]
 		for _, loc := range region.locations {
 			loc.calcCorners()
 			region.edges += loc.corners
@@ -225,6 +256,48 @@ func partTwo() int {
 	return result
 }
 
+func printReport() {
+	fmt.Printf("%6s %5s %6s %9s %6s  %s\n", "region", "plant", "area", "perimeter", "sides", "bounds")
+	for _, r := range regions {
+		fmt.Printf("%6d %5s %6d %9d %6d  [%d, %d] - [%d, %d]\n",
+			r.id, r.value, r.area, r.perimeter, r.edges, r.minRow, r.minCol, r.maxRow, r.maxCol)
+	}
+}
+
+// Plant letters get reused by separate regions so each plot is printed as its
+// region id instead, padded so the columns still line up
+func printLabels() {
+	width := len(strconv.Itoa(len(regions) - 1))
+	for _, row := range grid {
+		labels := []string{}
+		for _, loc := range row {
+			labels = append(labels, fmt.Sprintf("%*d", width, loc.region))
+		}
+		fmt.Println(strings.Join(labels, " "))
+	}
+}
+
+func writeJSON(filename string, partOne, partTwo int) error {
+	garden := gardenJSON{PartOne: partOne, PartTwo: partTwo, Regions: []regionJSON{}}
+	for _, r := range regions {
+		garden.Regions = append(garden.Regions, regionJSON{
+			ID:            r.id,
+			Plant:         r.value,
+			Area:          r.area,
+			Perimeter:     r.perimeter,
+			Sides:         r.edges,
+			Price:         r.price,
+			DiscountPrice: r.area * r.edges,
+			BoundingBox:   boundingBoxJSON{MinRow: r.minRow, MinCol: r.minCol, MaxRow: r.maxRow, MaxCol: r.maxCol},
+		})
+	}
+	data, err := json.MarshalIndent(garden, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0o644)
+}
+
 func timer() func() {
 	start := time.Now()
 	return func() {
@@ -233,7 +306,22 @@ func timer() func() {
 }
 
 func main() {
+	flag.Parse()
 	defer timer()()
-	fmt.Println("Part One:", partOne())
-	fmt.Println("Part Two:", partTwo())
+	partOne, partTwo := partOne(), partTwo()
+	fmt.Println("Part One:", partOne)
+	fmt.Println("Part Two:", partTwo)
+
+	if *reportFlag {
+		printReport()
+	}
+	if *labelsFlag {
+		printLabels()
+	}
+	if *jsonFlag != "" {
+		if err := writeJSON(*jsonFlag, partOne, partTwo); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
 }
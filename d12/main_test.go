@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCornerTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		mask    int
+		corners int
+	}{
+		{"alone", 0, 4},
+		{"surrounded", 0xff, 0},
+		// only right is in the region so both left corners are outside ones
+		{"end of a row", 1 << 2, 2},
+		{"straight through", 1<<2 | 1<<6, 0},
+		// right and down are in but not the diagonal between them
+		{"inside corner", 1<<2 | 1<<4, 2},
+		{"filled corner", 1<<2 | 1<<3 | 1<<4, 1},
+	}
+	for _, tt := range tests {
+		if got := cornerTable[tt.mask]; got != tt.corners {
+			t.Errorf("%s: cornerTable[%08b] = %d, want %d", tt.name, tt.mask, got, tt.corners)
+		}
+	}
+}
+
+func TestPartTwo(t *testing.T) {
+	tests := []struct {
+		name   string
+		garden string
+		want   int
+	}{
+		{"sample", "AAAA\nBBCD\nBBCC\nEEEC", 80},
+		{"e shaped", "EEEEE\nEXXXX\nEEEEE\nEXXXX\nEEEEE", 236},
+		{"enclaves", "AAAAAA\nAAABBA\nAAABBA\nABBAAA\nABBAAA\nAAAAAA", 368},
+		{"enclave in a wide map", "AAAAAAAA\nABBBBBBA\nAAAAAAAA", 168},
+		{"enclaves in a tall map", "AAA\nABA\nAAA\nABA\nAAA", 164},
+		// 17 columns by 9 rows, the square only corner checks gave 1056
+		{"rectangular", strings.Join([]string{
+			"ACCABCBCCACABBCAA",
+			"CBCCBBCAACACBCACA",
+			"ACABABBCCBCBBCCBA",
+			"BAAABABCBCBBCBCBC",
+			"CBCABCABCCCACBCCC",
+			"ACCACCBBAABCBABAB",
+			"AABBBAACCABCCBCBC",
+			"AABAAACCAABBCBACA",
+			"BBBABBBCBCCCCACCB",
+		}, "\n"), 1066},
+	}
+	for _, tt := range tests {
+		loadGarden(strings.NewReader(tt.garden))
+		partOne()
+		if got := partTwo(); got != tt.want {
+			t.Errorf("%s: partTwo() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,66 +2,125 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"math"
+	"math/big"
 	"os"
 	"strings"
 	"time"
 )
 
-var machines []machine
+var (
+	machines      []machine
+	pressesFlag   = flag.Bool("presses", false, "print how many times each button is pressed for each machine")
+	costsFlag     = flag.String("costs", DEFAULT_COSTS, "tokens per press for buttons that don't give a cost of their own")
+	searchFlag    = flag.Int("search", 200, "with no press limit, the most presses tried for each button beyond the two solved exactly")
+	errNoSolution = errors.New("no way to win")
+)
 
+// cost is NO_COST until it's read from the line or filled in from -costs
 type button struct {
-	x float64
-	y float64
+	label string
+	x     int
+	y     int
+	cost  int
 }
 
 type machine struct {
-	a      button
-	b      button
-	prizeX float64
-	prizeY float64
-	winner bool
-	cost   int
+	buttons []button
+	prizeX  int
+	prizeY  int
 }
 
 const (
 	// stated in the problem as the max
 	MAX_BUTTON_PRESSES = 100
 	PRIZE_OFFSET       = 10000000000000
+	DEFAULT_COSTS      = "A=3,B=1"
+	NO_COST            = -1
+	// No limit on presses for part two
+	UNLIMITED = -1
 )
 
 func init() {
 	scanner := bufio.NewScanner(os.Stdin)
-	currentMachine := machine{winner: false, cost: -1}
-	var aX, aY, bX, bY, pX, pY float64
+	currentMachine := machine{}
+	var pX, pY int
 	for scanner.Scan() {
 		line := scanner.Text()
 
-		if strings.HasPrefix(line, "Button A") {
-			fmt.Fscanf(strings.NewReader(line), "Button A: X+%f, Y+%f", &aX, &aY)
-			continue
-		}
-
-		if strings.HasPrefix(line, "Button B") {
-			fmt.Fscanf(strings.NewReader(line), "Button B: X+%f, Y+%f", &bX, &bY)
+		if strings.HasPrefix(line, "Button") {
+			b, err := parseButton(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			currentMachine.buttons = append(currentMachine.buttons, b)
 			continue
 		}
 
 		if strings.HasPrefix(line, "Prize") {
-			fmt.Fscanf(strings.NewReader(line), "Prize: X=%f, Y=%f", &pX, &pY)
+			fmt.Fscanf(strings.NewReader(line), "Prize: X=%d, Y=%d", &pX, &pY)
 
-			currentMachine.a = button{x: aX, y: aY}
-			currentMachine.b = button{x: bX, y: bY}
 			currentMachine.prizeX = pX
 			currentMachine.prizeY = pY
 			machines = append(machines, currentMachine)
-			currentMachine = machine{winner: false, cost: -1}
+			currentMachine = machine{}
 			continue
 		}
 	}
 }
 
+// Any label works and a machine can have any number of buttons, the cost
+// can be given at the end of the line
+// Button A: X+94, Y+34
+// Button C: X+5, Y-7, Cost 2
+func parseButton(line string) (button, error) {
+	label, rest, ok := strings.Cut(strings.TrimPrefix(line, "Button"), ":")
+	b := button{label: strings.TrimSpace(label), cost: NO_COST}
+	if !ok || b.label == "" {
+		return b, fmt.Errorf("invalid button %q", line)
+	}
+	movement, cost, hasCost := strings.Cut(rest, ", Cost")
+	if _, err := fmt.Sscanf(movement, " X%d, Y%d", &b.x, &b.y); err != nil {
+		return b, fmt.Errorf("invalid button %q", line)
+	}
+	if hasCost {
+		if _, err := fmt.Sscanf(cost, "%d", &b.cost); err != nil || b.cost < 0 {
+			return b, fmt.Errorf("invalid cost in %q", line)
+		}
+	}
+	return b, nil
+}
+
+// Fills in any button without a cost of its own from a spec like A=3,B=1
+func applyCosts(spec string) error {
+	costs := map[string]int{}
+	for _, part := range strings.Split(spec, ",") {
+		label, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		var cost int
+		if _, err := fmt.Sscanf(value, "%d", &cost); !ok || err != nil || cost < 0 {
+			return fmt.Errorf("invalid cost %q", part)
+		}
+		costs[label] = cost
+	}
+	for i := range machines {
+		for j, b := range machines[i].buttons {
+			if b.cost != NO_COST {
+				continue
+			}
+			cost, ok := costs[b.label]
+			if !ok {
+				return fmt.Errorf("machine %d: no cost for button %s", i+1, b.label)
+			}
+			machines[i].buttons[j].cost = cost
+		}
+	}
+	return nil
+}
+
 // Given the pair of equations that must be true to 'win'
 // a*m.a.x+b*m.b.x == m.prizeX
 // a*m.a.y+b*m.b.y == m.prizeY
@@ -93,48 +152,292 @@ func init() {
 //			   		  prizeX - Ax * i
 //	 ButtonPresses = -------------------
 //	            			Bx
-func (m machine) playOptimal(offsetPrizes bool) (bool, int) {
+//
+// Everything is kept as integers, both numerators are built with big.Int so
+// the products can't overflow however far the prizes get offset, and the
+// determinant (Ax * By) - (Ay * Bx) goes on the bottom of a big.Rat so a
+// fractional number of presses just isn't an int
+// When the determinant is 0 both buttons move the claw along the same line so
+// there's either no solution or lots of them, see solveCollinear
+func solvePair(a, b button, prizeX, prizeY, maxPresses int) ([2]int, error) {
+	det := a.x*b.y - a.y*b.x
+	if det == 0 {
+		return solveCollinear(a, b, prizeX, prizeY, maxPresses)
+	}
+
+	// x*y - z*w without leaving big.Int
+	cross := func(x, y, z, w int) *big.Int {
+		left := new(big.Int).Mul(big.NewInt(int64(x)), big.NewInt(int64(y)))
+		right := new(big.Int).Mul(big.NewInt(int64(z)), big.NewInt(int64(w)))
+		return left.Sub(left, right)
+	}
+	bigDet := big.NewInt(int64(det))
+	aPressed := new(big.Rat).SetFrac(cross(prizeX, b.y, prizeY, b.x), bigDet)
+	bPressed := new(big.Rat).SetFrac(cross(a.x, prizeY, a.y, prizeX), bigDet)
+	// You cannot press a button a fractional or negative number of times
+	if !aPressed.IsInt() || !bPressed.IsInt() || aPressed.Sign() < 0 || bPressed.Sign() < 0 {
+		return [2]int{}, errNoSolution
+	}
+	if !aPressed.Num().IsInt64() || !bPressed.Num().IsInt64() {
+		return [2]int{}, fmt.Errorf("needs more presses than fit in an int")
+	}
+
+	p := [2]int{int(aPressed.Num().Int64()), int(bPressed.Num().Int64())}
+	if maxPresses != UNLIMITED && (p[0] > maxPresses || p[1] > maxPresses) {
+		return [2]int{}, fmt.Errorf("needs %s %d %s %d, more than %d presses", a.label, p[0], b.label, p[1], maxPresses)
+	}
+	return p, nil
+}
+
+// Both buttons point the same way so the prize has to be on that line too,
+// after which it's one equation in one dimension
+// Ax * i + Bx * j = prizeX
+// Extended Euclid gives one solution (i0, j0) if gcd(Ax, Bx) divides prizeX
+// and every other is i = i0 + k * Bx/g, j = j0 - k * Ax/g. The cost 3i + j
+// changes by the same amount for every step of k so the cheapest is at one
+// end of the range of k that keeps i and j in bounds
+func solveCollinear(a, b button, prizeX, prizeY, maxPresses int) ([2]int, error) {
+	// Use whichever axis the buttons actually move along
+	dirX, dirY := a.x, a.y
+	if dirX == 0 && dirY == 0 {
+		dirX, dirY = b.x, b.y
+	}
+	if prizeX*dirY != prizeY*dirX {
+		return [2]int{}, errNoSolution
+	}
+	aStep, bStep, target := a.x, b.x, prizeX
+	if dirX == 0 {
+		aStep, bStep, target = a.y, b.y, prizeY
+	}
+	if aStep == 0 && bStep == 0 {
+		// Neither button moves the claw at all so the prize has to be where
+		// it starts on both axes, not just the one picked above
+		if prizeX == 0 && prizeY == 0 {
+			return [2]int{}, nil
+		}
+		return [2]int{}, errNoSolution
+	}
+
+	g, s, t := extendedGCD(aStep, bStep)
+	if target%g != 0 {
+		return [2]int{}, errNoSolution
+	}
+	i0, j0 := s*(target/g), t*(target/g)
+	iStep, jStep := bStep/g, aStep/g
+
+	// k has to satisfy i0 + k*iStep >= 0 and j0 - k*jStep >= 0, plus the max
+	// presses on both. A step of 0 means that button's count doesn't move
+	lower, upper := math.MinInt, math.MaxInt
+	bound := func(base, step, limit int) {
+		// base + k*step in [0, limit]
+		if step == 0 {
+			if base < 0 || (limit != UNLIMITED && base > limit) {
+				lower, upper = 1, 0
+			}
+			return
+		}
+		if step > 0 {
+			lower = max(lower, ceilDiv(-base, step))
+			if limit != UNLIMITED {
+				upper = min(upper, floorDiv(limit-base, step))
+			}
+		} else {
+			upper = min(upper, floorDiv(base, -step))
+			if limit != UNLIMITED {
+				lower = max(lower, ceilDiv(base-limit, -step))
+			}
+		}
+	}
+	bound(i0, iStep, maxPresses)
+	bound(j0, -jStep, maxPresses)
+	if lower > upper {
+		return [2]int{}, errNoSolution
+	}
+
+	// Going up a step of k costs this much more, both ends can't be unbounded
+	// as i and j can't both keep growing while staying on target. When it
+	// costs nothing either end does, as long as it's a finite one
+	k := lower
+	slope := a.cost*iStep - b.cost*jStep
+	if slope < 0 || (slope == 0 && lower == math.MinInt) {
+		k = upper
+	}
+	if k == math.MinInt || k == math.MaxInt {
+		return [2]int{}, errNoSolution
+	}
+	return [2]int{i0 + k*iStep, j0 - k*jStep}, nil
+}
+
+// A lone button reaches the prize if the prize is a whole, non-negative
+// number of its moves along both axes
+func solveSingle(b button, prizeX, prizeY, maxPresses int) (int, error) {
+	if b.x == 0 && b.y == 0 {
+		if prizeX == 0 && prizeY == 0 {
+			return 0, nil
+		}
+		return 0, errNoSolution
+	}
+	step, target := b.x, prizeX
+	if step == 0 {
+		step, target = b.y, prizeY
+	}
+	if target%step != 0 {
+		return 0, errNoSolution
+	}
+	n := target / step
+	if n < 0 || n*b.x != prizeX || n*b.y != prizeY {
+		return 0, errNoSolution
+	}
+	if maxPresses != UNLIMITED && n > maxPresses {
+		return 0, fmt.Errorf("needs %s %d, more than %d presses", b.label, n, maxPresses)
+	}
+	return n, nil
+}
+
+// Returns g = gcd(a, b) and s, t where a*s + b*t = g, g is never negative
+func extendedGCD(a, b int) (int, int, int) {
+	oldR, r := a, b
+	oldS, s := 1, 0
+	oldT, t := 0, 1
+	for r != 0 {
+		q := oldR / r
+		oldR, r = r, oldR-q*r
+		oldS, s = s, oldS-q*s
+		oldT, t = t, oldT-q*t
+	}
+	if oldR < 0 {
+		return -oldR, -oldS, -oldT
+	}
+	return oldR, oldS, oldT
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
+
+func ceilDiv(a, b int) int {
+	return -floorDiv(-a, b)
+}
+
+// Winning is a small integer linear program, minimise the sum of presses *
+// cost with the presses adding up to the prize on both axes. Two equations
+// pin down two of the buttons exactly, so every pair of buttons takes a turn
+// at being solved with solvePair while the rest are tried at every count up
+// to the press limit. With no limit that search stops at -search presses per
+// extra button, which won't spot a cheaper answer that needs more
+// Returns how many times each button is pressed, in the same order as m.buttons
+func (m machine) playOptimal(offsetPrizes bool, maxPresses int) ([]int, error) {
 	if offsetPrizes {
 		m.prizeX += PRIZE_OFFSET
 		m.prizeY += PRIZE_OFFSET
 	}
-	aPressed := ((m.prizeX * m.b.y) - (m.prizeY * m.b.x)) / ((m.a.x * m.b.y) - (m.a.y * m.b.x))
-	bPressed := (m.prizeX - (m.a.x * aPressed)) / m.b.x
 
-	// Check is result mod % 1 == 0, basically is it an integer result. Some solutions will have fractional answers which mean this is not viable
-	// You cannot press a button a fractional number of times
-	if math.Mod(aPressed, 1) == 0 && math.Mod(bPressed, 1) == 0 {
-		// fmt.Printf("Won by pressing A: %f B: %f\n", aPressed, bPressed)
-		return true, int((3 * aPressed) + bPressed)
+	switch len(m.buttons) {
+	case 0:
+		if m.prizeX == 0 && m.prizeY == 0 {
+			return []int{}, nil
+		}
+		return nil, errNoSolution
+	case 1:
+		n, err := solveSingle(m.buttons[0], m.prizeX, m.prizeY, maxPresses)
+		return []int{n}, err
+	case 2:
+		p, err := solvePair(m.buttons[0], m.buttons[1], m.prizeX, m.prizeY, maxPresses)
+		return p[:], err
 	}
 
-	return false, 0
-}
-
-func partOne() int {
-	result := 0
+	searchLimit := maxPresses
+	if searchLimit == UNLIMITED {
+		searchLimit = *searchFlag
+	}
 
-	for _, machine := range machines {
-		isWinner, cost := machine.playOptimal(false)
-		if isWinner {
-			result += cost
+	var best []int
+	bestCost := math.MaxInt
+	current := make([]int, len(m.buttons))
+	for first := range m.buttons {
+		for second := first + 1; second < len(m.buttons); second++ {
+			// Walks the extra buttons in order, x and y are what's left to
+			// reach and cost what's been spent so far
+			var search func(idx, x, y, cost int)
+			search = func(idx, x, y, cost int) {
+				if cost >= bestCost {
+					return
+				}
+				if idx == len(m.buttons) {
+					p, err := solvePair(m.buttons[first], m.buttons[second], x, y, maxPresses)
+					if err != nil {
+						return
+					}
+					total := cost + p[0]*m.buttons[first].cost + p[1]*m.buttons[second].cost
+					if total < bestCost {
+						current[first], current[second] = p[0], p[1]
+						best, bestCost = append([]int{}, current...), total
+					}
+					return
+				}
+				if idx == first || idx == second {
+					search(idx+1, x, y, cost)
+					return
+				}
+				b := m.buttons[idx]
+				for n := range searchLimit + 1 {
+					current[idx] = n
+					search(idx+1, x-n*b.x, y-n*b.y, cost+n*b.cost)
+				}
+				current[idx] = 0
+			}
+			search(0, m.prizeX, m.prizeY, 0)
 		}
 	}
-	return result
+	if best == nil {
+		return nil, errNoSolution
+	}
+	return best, nil
 }
 
-func partTwo() int {
+func (m machine) cost(p []int) int {
+	total := 0
+	for i, b := range m.buttons {
+		total += p[i] * b.cost
+	}
+	return total
+}
+
+func play(offsetPrizes bool, maxPresses int) int {
 	result := 0
 
-	for _, machine := range machines {
-		isWinner, cost := machine.playOptimal(true)
-		if isWinner {
-			result += cost
+	for i, machine := range machines {
+		p, err := machine.playOptimal(offsetPrizes, maxPresses)
+		if *pressesFlag {
+			if err != nil {
+				fmt.Printf("Machine %d: %v\n", i+1, err)
+			} else {
+				counts := []string{}
+				for j, b := range machine.buttons {
+					counts = append(counts, fmt.Sprintf("%s %d", b.label, p[j]))
+				}
+				fmt.Printf("Machine %d: %s costs %d\n", i+1, strings.Join(counts, " "), machine.cost(p))
+			}
+		}
+		if err == nil {
+			result += machine.cost(p)
 		}
 	}
 	return result
 }
 
+func partOne() int {
+	return play(false, MAX_BUTTON_PRESSES)
+}
+
+func partTwo() int {
+	return play(true, UNLIMITED)
+}
+
 func timer() func() {
 	start := time.Now()
 	return func() {
@@ -143,6 +446,11 @@ func timer() func() {
 }
 
 func main() {
+	flag.Parse()
+	if err := applyCosts(*costsFlag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	defer timer()()
 	fmt.Println("Part One:", partOne())
 	fmt.Println("Part Two:", partTwo())
@@ -2,8 +2,16 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +24,31 @@ const (
 	GRID_ROWS   = 103
 	GRID_COLS   = 101
 	NUM_SECONDS = 100
+	// In the tree frame the robots bunch up so the spread of their rows and
+	// columns drops well below what it is the rest of the time, anything
+	// not under this fraction of the average isn't treated as a tree
+	TREE_VARIANCE_RATIO = 0.75
+	// Palette indexes for the exported images
+	BACKGROUND_COLOUR = 0
+	BOUNDARY_COLOUR   = 1
+	ROBOT_COLOUR      = 2
+)
+
+var (
+	framesFlag = flag.String("frames", "", "seconds to export, e.g. 7000-7010,7500 (defaults to the part two answer)")
+	pngFlag    = flag.String("png", "", "directory to write a PNG of each exported second to")
+	gifFlag    = flag.String("gif", "", "file to write an animated GIF of the exported seconds to")
+	scaleFlag  = flag.Int("scale", 4, "pixels per grid cell in exported images")
+	delayFlag  = flag.Int("delay", 20, "hundredths of a second between GIF frames")
+	splitFlag  = flag.String("split", "2x2", "how many rows and columns of quadrants to split the grid into")
+	countsFlag = flag.Bool("counts", false, "print how many robots are in each quadrant")
+	splitRows  = 2
+	splitCols  = 2
+	palette    = color.Palette{
+		BACKGROUND_COLOUR: color.RGBA{0x0f, 0x0f, 0x23, 0xff},
+		BOUNDARY_COLOUR:   color.RGBA{0x66, 0x1a, 0x1a, 0xff},
+		ROBOT_COLOUR:      color.RGBA{0x00, 0xcc, 0x00, 0xff},
+	}
 )
 
 type grid [][]int
@@ -77,11 +110,6 @@ func init() {
 	}
 }
 
-func (r *robot) reset() {
-	r.currentRow = r.startRow
-	r.currentCol = r.startCol
-}
-
 func (g grid) print() {
 	fmt.Println("======= Grid =======")
 	for _, row := range g {
@@ -110,56 +138,96 @@ func (g grid) printTree() {
 	}
 }
 
-func (g grid) generateQuadrants() []quadrant {
-	middleRow := ((len(g)) / 2)
-	middleCol := ((len(g[0])) / 2)
-
-	return []quadrant{
-		{
-			label:    "topLeft",
-			startCol: 0,
-			endCol:   middleCol - 1,
-			startRow: 0,
-			endRow:   middleRow - 1,
-			count:    0,
-		},
-		{
-			label:    "topRight",
-			startCol: middleCol + 1,
-			endCol:   len(g[0]) - 1,
-			startRow: 0,
-			endRow:   middleRow - 1,
-			count:    0,
-		},
-		{
-			label:    "bottomLeft",
-			startCol: 0,
-			endCol:   middleCol - 1,
-			startRow: middleRow + 1,
-			endRow:   len(g) - 1,
-			count:    0,
-		},
-		{
-			label:    "bottomRight",
-			startCol: middleCol + 1,
-			endCol:   len(g[0]) - 1,
-			startRow: middleRow + 1,
-			endRow:   len(g) - 1,
-			count:    0,
-		},
+// Splits the grid into rows x cols quadrants. Each cell belongs to whichever
+// band its centre falls in, a cell whose centre sits exactly on a dividing
+// line isn't in any quadrant. Working in doubled coordinates keeps it in ints,
+// cell c's centre is at 2c+1 and the dividing lines at multiples of 2n/parts.
+// For a 2 way split of 101 columns the line is at 101 which is column 50's
+// centre so that column is left out, with 100 columns the line is at 100
+// which falls between columns 49 and 50 so every column is in a half
+func (g grid) subdivide(rows, cols int) []quadrant {
+	rowBands := splitBands(len(g), rows)
+	colBands := splitBands(len(g[0]), cols)
+
+	quadrants := []quadrant{}
+	for r, rowBand := range rowBands {
+		for c, colBand := range colBands {
+			label := fmt.Sprintf("row %d col %d", r, c)
+			if rows == 2 && cols == 2 {
+				label = [2]string{"top", "bottom"}[r] + [2]string{"Left", "Right"}[c]
+			}
+			quadrants = append(quadrants, quadrant{
+				label:    label,
+				startRow: rowBand[0],
+				endRow:   rowBand[1],
+				startCol: colBand[0],
+				endCol:   colBand[1],
+			})
+		}
+	}
+	return quadrants
+}
+
+// The first and last index of each of the parts n cells are split into, a
+// band can be empty (end < start) when there are more parts than cells
+func splitBands(n, parts int) [][2]int {
+	bands := make([][2]int, parts)
+	for i := range bands {
+		bands[i] = [2]int{n, -1}
+	}
+	for c := range n {
+		centre := (2*c + 1) * parts
+		if centre%(2*n) == 0 {
+			continue
+		}
+		band := centre / (2 * n)
+		bands[band][0] = min(bands[band][0], c)
+		bands[band][1] = max(bands[band][1], c)
+	}
+	for i := range bands {
+		if bands[i][1] == -1 {
+			bands[i] = [2]int{0, -1}
+		}
+	}
+	return bands
+}
+
+// Accepts splits like 2x2 or 3x4, rows first
+func parseSplit(spec string) (int, int, error) {
+	first, second, ok := strings.Cut(spec, "x")
+	rows, rowErr := strconv.Atoi(first)
+	cols, colErr := strconv.Atoi(second)
+	if !ok || rowErr != nil || colErr != nil || rows < 1 || cols < 1 {
+		return 0, 0, fmt.Errorf("invalid split %q, expected ROWSxCOLS", spec)
 	}
+	return rows, cols, nil
+}
+
+// Robots wrap around so there's no need to step through every second, where
+// a robot is at second t is just where it started plus t lots of its velocity
+// wrapped to the grid. Go's % keeps the sign so add the size back for negatives
+// If [r.velocityRow,r.velocityCol] = [-3, 2] on a 7 x 11 grid and r starts at [1, 4]
+// after 2 seconds
+// row = ((1 + -3*2) % 7 + 7) % 7 = (-5 + 7) % 7 = 2
+// col = ((4 + 2*2) % 11 + 11) % 11 = 8
+// so robot will be at [2, 8]
+func (r *robot) positionAt(t int) (int, int) {
+	row := ((r.startRow+r.velocityRow*t)%GRID_ROWS + GRID_ROWS) % GRID_ROWS
+	col := ((r.startCol+r.velocityCol*t)%GRID_COLS + GRID_COLS) % GRID_COLS
+	return row, col
 }
 
-// If [r.velocityRow,r.velocityCol] = [-3, 2] and len(g) = 7 and len(g[x]) = 11 (7 and 11 can be any positive value)
-// and r is currently at [1, 4] moving would give
-// r.currentRow = ((1 + -3) + 7) % 7 = (-2 + 7) % 7 = 5 % 7 = 5
-// r. currentCol = ((4 + 2) + 11) % 11 = (6 + 11) % 11 = 17 % 11 = 6
-// so robot will be at [5, 6]
-func (r *robot) move(g [][]int) {
-	g[r.currentRow][r.currentCol]--
-	r.currentRow = ((r.currentRow + r.velocityRow) + len(g)) % len(g)
-	r.currentCol = ((r.currentCol + r.velocityCol) + len(g[r.currentRow])) % len(g[r.currentRow])
-	g[r.currentRow][r.currentCol]++
+// Moves every robot straight to second t and returns the grid of them
+func gridAt(t int) grid {
+	g := make(grid, GRID_ROWS)
+	for i := range g {
+		g[i] = make([]int, GRID_COLS)
+	}
+	for _, robot := range robots {
+		robot.currentRow, robot.currentCol = robot.positionAt(t)
+		g[robot.currentRow][robot.currentCol]++
+	}
+	return g
 }
 
 func (q quadrant) printContent(w grid) {
@@ -177,21 +245,19 @@ func (q quadrant) printContent(w grid) {
 	fmt.Printf("======= End Quadrant %s =======\n", q.label)
 }
 
-func safetyFactor(quadrants []quadrant) int {
-	result := 0
-	for _, quadrant := range quadrants {
-		quadrant.updateRobotCount(world)
-		if result == 0 && quadrant.count > 0 {
-			result = quadrant.count
-			continue
-		}
-		result *= quadrant.count
+// Counts the robots in each quadrant and returns them along with the product
+// of the counts, a single empty quadrant makes the whole thing 0
+func safetyFactor(quadrants []quadrant, g grid) ([]quadrant, int) {
+	result := 1
+	for i := range quadrants {
+		quadrants[i].updateRobotCount(g)
+		result *= quadrants[i].count
 	}
-
-	return result
+	return quadrants, result
 }
 
 func (q *quadrant) updateRobotCount(w grid) {
+	q.count = 0
 	for r := q.startRow; r <= q.endRow; r++ {
 		for c := q.startCol; c <= q.endCol; c++ {
 			q.count += w[r][c]
@@ -204,83 +270,255 @@ func aToIIgnoreError(s string) int {
 	return result
 }
 
-func (q *quadrant) overlappingRobots(w grid) bool {
-	for r := q.startRow; r <= q.endRow; r++ {
-		for c := q.startCol; c <= q.endCol; c++ {
-			value := w[r][c]
-			if value > 0 && value != 1 {
-				fmt.Println("Quadrant has overlapping robots:", w[r][c])
-				return true
-			}
-		}
+// How spread out the robots are along one axis at second t, low variance
+// means they're bunched together
+func variance(t int, axis func(row, col int) int) float64 {
+	sum, sumSquares := 0.0, 0.0
+	for _, robot := range robots {
+		v := float64(axis(robot.positionAt(t)))
+		sum += v
+		sumSquares += v * v
 	}
-	return false
+	n := float64(len(robots))
+	mean := sum / n
+	return sumSquares/n - mean*mean
 }
 
-func treeFound(quadrants []quadrant, w grid) bool {
-	for _, quadrant := range quadrants {
-		if quadrant.overlappingRobots(w) {
-			return false
+// An axis repeats every period seconds so only those seconds need checking to
+// find when the robots are tightest along it. Returns that second and how its
+// variance compares to the average
+func tightestSecond(period int, axis func(row, col int) int) (int, float64) {
+	best, bestVariance, total := 0, math.Inf(1), 0.0
+	for t := range period {
+		v := variance(t, axis)
+		total += v
+		if v < bestVariance {
+			best, bestVariance = t, v
 		}
 	}
-	return false
+	return best, bestVariance / (total / float64(period))
 }
 
-func (g grid) treeFound() bool {
-	for _, row := range g {
-		for _, value := range row {
-			if value > 1 {
-				return false
-			}
-		}
+// Extended Euclid, returns g = gcd(a, b) along with x, y where ax + by = g
+func extendedGCD(a, b int) (int, int, int) {
+	if b == 0 {
+		return a, 1, 0
 	}
-	return true
+	g, x, y := extendedGCD(b, a%b)
+	return g, y, x - (a/b)*y
 }
 
-func bothParts() (int, int) {
-	secondsElapsed := 1
-	partOne := 0
-	partTwo := 0
-	quadrants := world.generateQuadrants()
+// Chinese Remainder Theorem, finds the first t >= 0 where t % m1 == r1 and
+// t % m2 == r2. Every t that works is that plus a multiple of lcm(m1, m2)
+func crt(r1, m1, r2, m2 int) (int, int, error) {
+	g, x, _ := extendedGCD(m1, m2)
+	if (r2-r1)%g != 0 {
+		return 0, 0, fmt.Errorf("no second is %d mod %d and %d mod %d", r1, m1, r2, m2)
+	}
+	lcm := m1 / g * m2
+	// m1 * x = g (mod m2) so stepping r1 by m1 * x * (r2 - r1) / g lands on r2
+	step := ((r2 - r1) / g * x) % (m2 / g)
+	t := ((r1+m1*step)%lcm + lcm) % lcm
+	return t, lcm, nil
+}
+
+// The tree is when the robots are bunched up on both axes at once. The columns
+// repeat every GRID_COLS seconds and the rows every GRID_ROWS so find the
+// tightest second for each and the CRT gives the one second where both happen.
+// After lcm(GRID_ROWS, GRID_COLS) seconds everything repeats so if either
+// axis never bunches up there's no tree to find
+func findTree() (int, error) {
+	if len(robots) == 0 {
+		return 0, errors.New("no robots")
+	}
+	colSecond, colRatio := tightestSecond(GRID_COLS, func(_, col int) int { return col })
+	rowSecond, rowRatio := tightestSecond(GRID_ROWS, func(row, _ int) int { return row })
+	t, period, err := crt(colSecond, GRID_COLS, rowSecond, GRID_ROWS)
+	if err != nil {
+		return 0, err
+	}
+	if colRatio > TREE_VARIANCE_RATIO || rowRatio > TREE_VARIANCE_RATIO {
+		return 0, fmt.Errorf("no tree found in the %d seconds before the robots repeat", period)
+	}
+	return t, nil
+}
+
+func timer() func() {
+	start := time.Now()
+	return func() {
+		fmt.Printf("took %v\n", time.Since(start))
+	}
+}
 
-	for secondsElapsed <= 100 {
-		for _, robot := range robots {
-			robot.move(world)
+// Accepts a comma separated list of seconds and first-last ranges
+func parseSeconds(spec string) ([]int, error) {
+	seconds := []int{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid second %q", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for t := start; t <= end; t++ {
+			seconds = append(seconds, t)
 		}
-		secondsElapsed++
 	}
+	return seconds, nil
+}
 
-	partOne = safetyFactor(quadrants)
+// Draws the robots at second t with each cell scale pixels wide. Quadrant
+// boundaries are drawn first so robots on them still show up on top. Rows
+// and columns that don't belong to any quadrant are shaded in and where two
+// bands touch with nothing left out between them a line is drawn along the
+// edge of the second one instead
+func renderFrame(t, scale int) *image.Paletted {
+	g := gridAt(t)
+	width, height := GRID_COLS*scale, GRID_ROWS*scale
+	img := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	fillRect := func(x0, y0, x1, y1 int, colour uint8) {
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				img.SetColorIndex(x, y, colour)
+			}
+		}
+	}
 
-	// Not sure if this is always the case but the assumption has been made that the christmas tree is shown when 0 robots overlap with any other robot
-	// also assumes the tree is never formed in part 1, these assumptions might fail for some inputs
-	for {
-		for _, robot := range robots {
-			robot.move(world)
+	rowBands := splitBands(GRID_ROWS, splitRows)
+	colBands := splitBands(GRID_COLS, splitCols)
+	drawBoundaries := func(bands [][2]int, size int, line func(start, end int)) {
+		inBand := make([]bool, size)
+		for _, band := range bands {
+			for i := band[0]; i <= band[1]; i++ {
+				inBand[i] = true
+			}
 		}
+		for i, in := range inBand {
+			if !in {
+				line(i*scale, (i+1)*scale)
+			}
+		}
+		for i := 1; i < len(bands); i++ {
+			previous, band := bands[i-1], bands[i]
+			if previous[1] >= previous[0] && band[1] >= band[0] && previous[1]+1 == band[0] {
+				line(band[0]*scale, band[0]*scale+1)
+			}
+		}
+	}
+	drawBoundaries(rowBands, GRID_ROWS, func(start, end int) { fillRect(0, start, width, end, BOUNDARY_COLOUR) })
+	drawBoundaries(colBands, GRID_COLS, func(start, end int) { fillRect(start, 0, end, height, BOUNDARY_COLOUR) })
 
-		if world.treeFound() {
-			partTwo = secondsElapsed
-			world.printTree()
-			break
+	for r, row := range g {
+		for c, count := range row {
+			if count > 0 {
+				fillRect(c*scale, r*scale, (c+1)*scale, (r+1)*scale, ROBOT_COLOUR)
+			}
 		}
+	}
+	return img
+}
+
+func writePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-		secondsElapsed++
+// Writes a PNG per second into pngDir and/or all of them as one GIF
+func exportFrames(seconds []int, pngDir, gifFile string, scale, delay int) error {
+	animation := &gif.GIF{}
+	for _, t := range seconds {
+		img := renderFrame(t, scale)
+		if pngDir != "" {
+			if err := writePNG(filepath.Join(pngDir, fmt.Sprintf("second_%05d.png", t)), img); err != nil {
+				return err
+			}
+		}
+		// Frames are only kept around when they're going in the GIF, a long
+		// range of PNGs would otherwise all sit in memory at once
+		if gifFile != "" {
+			animation.Image = append(animation.Image, img)
+			animation.Delay = append(animation.Delay, delay)
+		}
+	}
+	if gifFile == "" {
+		return nil
+	}
+	f, err := os.Create(gifFile)
+	if err != nil {
+		return err
 	}
+	if err := gif.EncodeAll(f, animation); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	return partOne, partTwo
+func partOne() int {
+	world = gridAt(NUM_SECONDS)
+	quadrants, result := safetyFactor(world.subdivide(splitRows, splitCols), world)
+	if *countsFlag {
+		for _, q := range quadrants {
+			fmt.Printf("%s (s: [%d, %d] e: [%d, %d]): %d\n", q.label, q.startRow, q.startCol, q.endRow, q.endCol, q.count)
+		}
+	}
+	return result
 }
 
-func timer() func() {
-	start := time.Now()
-	return func() {
-		fmt.Printf("took %v\n", time.Since(start))
+func partTwo() (int, error) {
+	t, err := findTree()
+	if err != nil {
+		return 0, err
 	}
+	world = gridAt(t)
+	world.printTree()
+	return t, nil
 }
 
 func main() {
+	flag.Parse()
+	var err error
+	if splitRows, splitCols, err = parseSplit(*splitFlag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	defer timer()()
-	partOne, partTwo := bothParts()
-	fmt.Println("Part One:", partOne)
-	fmt.Println("Part Two:", partTwo)
+	fmt.Println("Part One:", partOne())
+	partTwo, err := partTwo()
+	if err != nil {
+		fmt.Println("Part Two:", err)
+	} else {
+		fmt.Println("Part Two:", partTwo)
+	}
+
+	if *pngFlag == "" && *gifFlag == "" {
+		return
+	}
+	seconds := []int{partTwo}
+	if *framesFlag != "" {
+		if seconds, err = parseSeconds(*framesFlag); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "no tree to export, pass -frames")
+		os.Exit(1)
+	}
+	if err := exportFrames(seconds, *pngFlag, *gifFlag, *scaleFlag, *delayFlag); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
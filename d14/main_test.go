@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitBands(t *testing.T) {
+	tests := []struct {
+		name  string
+		n     int
+		parts int
+		want  [][2]int
+	}{
+		{"odd halves skip the middle", 11, 2, [][2]int{{0, 4}, {6, 10}}},
+		{"even halves", 10, 2, [][2]int{{0, 4}, {5, 9}}},
+		{"odd thirds", 7, 3, [][2]int{{0, 1}, {2, 4}, {5, 6}}},
+		{"even quarters skip the cells on a line", 6, 4, [][2]int{{0, 0}, {2, 2}, {3, 3}, {5, 5}}},
+		{"single part", 5, 1, [][2]int{{0, 4}}},
+		// more parts than cells leaves some bands empty
+		{"more parts than cells", 2, 5, [][2]int{{0, -1}, {0, 0}, {0, -1}, {1, 1}, {0, -1}}},
+	}
+	for _, tt := range tests {
+		if got := splitBands(tt.n, tt.parts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitBands(%d, %d) = %v, want %v", tt.name, tt.n, tt.parts, got, tt.want)
+		}
+	}
+}
+
+func newTestGrid(rows, cols int, robots ...[2]int) grid {
+	g := make(grid, rows)
+	for i := range g {
+		g[i] = make([]int, cols)
+	}
+	for _, r := range robots {
+		g[r[0]][r[1]]++
+	}
+	return g
+}
+
+func TestSafetyFactor(t *testing.T) {
+	tests := []struct {
+		name       string
+		g          grid
+		rows, cols int
+		counts     []int
+		want       int
+	}{
+		{
+			name:   "sample",
+			g:      newTestGrid(7, 11, [2]int{0, 0}, [2]int{0, 6}, [2]int{0, 6}, [2]int{0, 9}, [2]int{4, 0}, [2]int{4, 0}, [2]int{5, 0}, [2]int{6, 1}, [2]int{4, 6}),
+			rows:   2,
+			cols:   2,
+			counts: []int{1, 3, 4, 1},
+			want:   12,
+		},
+		{
+			name:   "empty quadrant",
+			g:      newTestGrid(7, 11, [2]int{0, 0}, [2]int{0, 6}, [2]int{6, 0}),
+			rows:   2,
+			cols:   2,
+			counts: []int{1, 1, 1, 0},
+			want:   0,
+		},
+		{
+			name:   "only the first quadrant empty",
+			g:      newTestGrid(6, 10, [2]int{0, 5}, [2]int{3, 0}, [2]int{3, 5}),
+			rows:   2,
+			cols:   2,
+			counts: []int{0, 1, 1, 1},
+			want:   0,
+		},
+		{
+			name:   "robots only on the middle lines",
+			g:      newTestGrid(7, 11, [2]int{3, 0}, [2]int{0, 5}),
+			rows:   2,
+			cols:   2,
+			counts: []int{0, 0, 0, 0},
+			want:   0,
+		},
+		{
+			name:   "even grid in thirds",
+			g:      newTestGrid(6, 6, [2]int{0, 0}, [2]int{2, 2}, [2]int{5, 5}, [2]int{5, 5}),
+			rows:   3,
+			cols:   1,
+			counts: []int{1, 1, 2},
+			want:   2,
+		},
+	}
+	for _, tt := range tests {
+		quadrants, got := safetyFactor(tt.g.subdivide(tt.rows, tt.cols), tt.g)
+		counts := []int{}
+		for _, q := range quadrants {
+			counts = append(counts, q.count)
+		}
+		if got != tt.want || !reflect.DeepEqual(counts, tt.counts) {
+			t.Errorf("%s: safetyFactor() = %v %d, want %v %d", tt.name, counts, got, tt.counts, tt.want)
+		}
+	}
+}
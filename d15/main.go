@@ -3,25 +3,110 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	WALL             = "#"
-	ROBOT            = "@"
-	EMPTY            = "."
-	BOX              = "O"
-	DOUBLE_BOX_LEFT  = "["
-	DOUBLE_BOX_RIGHT = "]"
+	WALL             byte = '#'
+	ROBOT            byte = '@'
+	EMPTY            byte = '.'
+	BOX              byte = 'O'
+	DOUBLE_BOX_LEFT  byte = '['
+	DOUBLE_BOX_RIGHT byte = ']'
+	NO_BOX                = -1
+	// The puzzle's boxes, single O's and [] for part two
+	DEFAULT_LEGEND = "O\n\n[]"
+	// Boxes moved by the last push are printed in yellow
+	HIGHLIGHT_START = "\033[33m"
+	HIGHLIGHT_END   = "\033[0m"
 )
 
 var gridInput []string
 var instructions string
 
-type grid [][]string
+var (
+	debugFlag  = flag.Bool("debug", false, "step through the moves, reading commands from the terminal or -script")
+	scriptFlag = flag.String("script", "", "file of debugger commands to run instead of reading the terminal")
+	everyFlag  = flag.Int("every", 0, "print the warehouse every N moves")
+	wideFlag   = flag.Bool("wide", false, "debug the part two (double width) warehouse")
+	legendFlag = flag.String("legend", "", "file of box shapes to use instead of O and [], the map is used as is")
+	boxShapes  []boxShape
+)
+
+// Row major, cell r,c is cells[r*width+c]
+type grid struct {
+	cells         []byte
+	width, height int
+}
+
+type (
+	position struct {
+		row, col int
+	}
+	// Any group of cells that always move together, cells[0] is the anchor
+	// (top most then left most cell) which the GPS is measured from. values
+	// is what each cell is drawn as
+	box struct {
+		cells  []int
+		values []byte
+	}
+	// A box from the legend, offsets are from the anchor
+	boxShape struct {
+		offsets []position
+		values  []byte
+	}
+	// g is kept as the picture of the warehouse, boxIDs says which box (if
+	// any) is in each cell so pushing never has to work out which [ goes
+	// with which ]
+	warehouse struct {
+		g      grid
+		boxIDs []int32
+		boxes  []box
+		robot  int
+		// Reused by every push so checking one doesn't allocate, a box has
+		// been queued this push if its seen value is the current stamp
+		queue []int32
+		seen  []uint32
+		stamp uint32
+		// Only used by the debugger, when recording every cell a move
+		// changes goes in changes and scoreDelta is how much the moved
+		// boxes changed the GPS by
+		recording  bool
+		changes    []cellChange
+		movedCells []int
+		scoreDelta int
+	}
+)
+
+type (
+	cellChange struct {
+		cell          int
+		before, after byte
+	}
+	// Every cell one instruction changed, so it can be played forwards or
+	// backwards without running processInstruction again. moved is every
+	// cell of every box the instruction pushed, where they ended up
+	moveRecord struct {
+		instruction        rune
+		changes            []cellChange
+		moved              []int
+		robotRow, robotCol int
+		score              int
+	}
+	debugger struct {
+		g                  grid
+		history            []moveRecord
+		position           int
+		startRow, startCol int
+		startScore         int
+	}
+)
 
 type direction struct {
 	rowOffset int
@@ -55,17 +140,120 @@ func init() {
 	instructions = instructionBuff.String()
 }
 
+// Short lines are padded out with walls so the grid is always a rectangle
 func createdGrid(lines []string) grid {
-	var g grid
+	g := grid{height: len(lines)}
 	for _, line := range lines {
-		row := []string{}
-		for _, r := range line {
-			row = append(row, string(r))
+		g.width = max(g.width, len(line))
+	}
+	g.cells = bytes.Repeat([]byte{WALL}, g.width*g.height)
+	for r, line := range lines {
+		copy(g.cells[r*g.width:], line)
+	}
+	return g
+}
+
+func (g grid) clone() grid {
+	g.cells = bytes.Clone(g.cells)
+	return g
+}
+
+// Shapes are separated by blank lines, spaces are not part of the shape so an
+// L shaped box and a 1x3 vertical box would be
+//
+//	A
+//	BC
+//
+//	^
+//	|
+//	v
+func parseLegend(text string) ([]boxShape, error) {
+	shapes := []boxShape{}
+	for _, block := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		shape := boxShape{}
+		var anchor position
+		for r, line := range strings.Split(strings.Trim(block, "\n"), "\n") {
+			for c, char := range line {
+				if char == ' ' {
+					continue
+				}
+				value := byte(char)
+				if char > 127 || value == WALL || value == EMPTY || value == ROBOT {
+					return nil, fmt.Errorf("%q can't be part of a box", char)
+				}
+				if len(shape.offsets) == 0 {
+					anchor = position{r, c}
+				}
+				shape.offsets = append(shape.offsets, position{r - anchor.row, c - anchor.col})
+				shape.values = append(shape.values, value)
+			}
+		}
+		if len(shape.offsets) > 0 {
+			shapes = append(shapes, shape)
 		}
-		g = append(g, row)
+	}
+	if len(shapes) == 0 {
+		return nil, fmt.Errorf("legend has no boxes")
+	}
+	return shapes, nil
+}
 
+// Reads the map top to bottom, left to right, so the first cell of a box we
+// come across is always its anchor. The first shape in the legend that fits
+// there claims the cells
+func newWarehouse(lines []string, shapes []boxShape) (*warehouse, error) {
+	w := &warehouse{g: createdGrid(lines)}
+	row, col := w.g.robotLocation()
+	w.robot = row*w.g.width + col
+	w.boxIDs = make([]int32, len(w.g.cells))
+	for i := range w.boxIDs {
+		w.boxIDs[i] = NO_BOX
 	}
-	return g
+
+	for i, value := range w.g.cells {
+		if value == WALL || value == EMPTY || value == ROBOT || w.boxIDs[i] != NO_BOX {
+			continue
+		}
+		r, c := i/w.g.width, i%w.g.width
+		found := false
+		for _, shape := range shapes {
+			if b, ok := w.fit(shape, r, c); ok {
+				for _, cell := range b.cells {
+					w.boxIDs[cell] = int32(len(w.boxes))
+				}
+				w.boxes = append(w.boxes, b)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%q at %d,%d isn't part of any box in the legend", value, r, c)
+		}
+	}
+	w.seen = make([]uint32, len(w.boxes))
+	return w, nil
+}
+
+// Checks if shape matches the map with its anchor at row, col
+func (w *warehouse) fit(shape boxShape, row, col int) (box, bool) {
+	b := box{}
+	for i, offset := range shape.offsets {
+		r, c := row+offset.row, col+offset.col
+		if !w.g.onGrid(r, c) {
+			return box{}, false
+		}
+		cell := r*w.g.width + c
+		if w.g.cells[cell] != shape.values[i] || w.boxIDs[cell] != NO_BOX {
+			return box{}, false
+		}
+		b.cells = append(b.cells, cell)
+		b.values = append(b.values, shape.values[i])
+	}
+	return b, true
+}
+
+func (w *warehouse) robotLocation() (int, int) {
+	return w.robot / w.g.width, w.robot % w.g.width
 }
 
 func timer() func() {
@@ -76,94 +264,43 @@ func timer() func() {
 }
 
 func (g grid) robotLocation() (int, int) {
-	for r, row := range g {
-		for c, v := range row {
-			if v == ROBOT {
-				return r, c
-			}
-		}
+	if i := bytes.IndexByte(g.cells, ROBOT); i != -1 {
+		return i / g.width, i % g.width
 	}
 	return -1, -1
 }
 
 func (g grid) print() {
-	for _, row := range g {
-		for _, v := range row {
-			fmt.Printf("%s", string(v))
-		}
-		fmt.Println()
+	for r := range g.height {
+		fmt.Println(string(g.cells[r*g.width : (r+1)*g.width]))
 	}
 }
 
 // The GPS coordinate of a box is equal to 100 times its distance from the top edge of the map plus its distance from the left edge of the map
-// Part 2 only cares about the nearest edge (so left box)
-func (g grid) score() int {
+// Part 2 only cares about the nearest edge (so left box), for any shape this is the anchor
+func (w *warehouse) score() int {
 	result := 0
-	for r, row := range g {
-		if r == 0 || r == len(g)-1 {
-			continue
-		}
-		for c, value := range row {
-			if value == BOX || value == DOUBLE_BOX_LEFT {
-				result += (100 * r) + c
-			}
-		}
+	for _, b := range w.boxes {
+		anchor := b.cells[0]
+		result += (100 * (anchor / w.g.width)) + anchor%w.g.width
 	}
 
 	return result
 }
 
 func (g grid) onGrid(row, col int) bool {
-	return row > -1 && col > -1 && row < len(g) && col < len(g[row])
-}
-
-// dfs here is used for horizontal movements only and boxes can'y overlap
-// basically it continually moves every [ or ] if it can
-// but only once it knows it safe to move
-// recursively runs until it finds an empty (.) then that will return true
-// then previous calls will move themselves along
-// Robot is moved elsewhere, if this returns true then move the robot
-// This could probably be a for-loop as its not really proper DFS
-// this basically will do the follow given
-// #.[][]@ -> recursive until it finds .
-// #.[][]@ -> #[.][]@ (empty founds, swaps then returns true)
-// #[.][]@ -> #[].[]@ recursive calls now see the true returns and start swapping
-// #[].[]@ -> #[][.]@
-// #[][.]@ -> #[][].@ back at our starting point, all funcs return, return true
-// move robot separately if DFS returned true
-// #[][].@ -> #[][]@.
-// We've now moved everything left one tile!
-func dfs(direction direction, row, col int, grid [][]string) bool {
-	nextCol, nextRow := row+direction.rowOffset, col+direction.colOffset
-
-	// Wall means nothing will move at all as there was no gap in the chain
-	if grid[nextCol][nextRow] == WALL {
-		return false
-	}
-
-	// We found a gap in the chain so simply swap the values in the grid around and return true
-	// This should only happen once per chain
-	if grid[nextCol][nextRow] == EMPTY {
-		grid[nextCol][nextRow], grid[row][col] = grid[row][col], grid[nextCol][nextRow]
-		return true
-	}
-
-	// If we are still checking boxes, then recursive DFS to see if movement if possible
-	// if it is, then swap the adjacent grid cells based on the direction
-	if grid[nextCol][nextRow] == DOUBLE_BOX_RIGHT || grid[nextCol][nextRow] == DOUBLE_BOX_LEFT {
-		if !dfs(direction, nextCol, nextRow, grid) {
-			return false
-		}
-		grid[row][col], grid[nextCol][nextRow] = grid[nextCol][nextRow], grid[row][col]
-	}
-	return true
+	return row > -1 && col > -1 && row < g.height && col < g.width
 }
 
-// BFS for vertical as boxes can overlap
-// will only allow movement if both spaces above or below all target boxes are empty (+ any new target boxes it finds)
-// if any of them are not it will return false and won't move anything
-// This is a bit more complication to demo
-// but given
+// Every box is pushed the same way no matter its size or shape. Starting from
+// the box the robot walks into, look at the cell each part of the box would
+// move into
+// wall -> nothing moves at all
+// empty (or part of the same box) -> fine
+// another box -> that box has to move too so check it the same way
+// once every box in the chain has been checked they all move together, this
+// works for pushing sideways into a row of boxes as well as up/down into boxes
+// that overlap like a pyramid
 // ##############
 // ##......##..##
 // ##..........##
@@ -171,190 +308,375 @@ func dfs(direction direction, row, col int, grid [][]string) bool {
 // ##....[]....##
 // ##.....@....##
 // ##############
-// we attempt to move up with so start the search robot (@) [y-1, x]s
-// queue = [[y-1, x]]
-// g[y-1][x] == ] we add the index of [ to the queue
-// queue = [[y-1, x], [y, x-1]]
-// next = [y-1, x] (queue: [[y, x-1]])
-// .. skip visited stuff ...
-// track what [y, x] we are on so we know what to move later if needed
-// neighbour := [next.Y-1, next.X] (-1 since we are moving up)
-// if g[neighbour.Y][neighbour.X] == WALL -> return false, not allowed to move
-// if g[neighbour.Y][neighbour.X] == EMPTY -> move onto next as the title has no importance yet
-// if g[neighbour.Y][neighbour.X] == ONE_SIDE_OF_BOX, then add the other side to the queue and process
-// Keep repeating, and if there is always space above the top most boxes (and space for all boxes) and move will happen
-// otherwise if you hit a wall for any box, then none of them will be moved as it exits out
-// Moves are only done at the end here!
-func bfs(d direction, row, col int, g grid) bool {
-	queue := [][]int{{
-		row, col,
-	}}
-	visitedMap := make(map[string]struct{})
-	visited := [][]int{}
-
-	// Which side of the box are we?
-	if g[row][col] == DOUBLE_BOX_RIGHT {
-		queue = append(queue, []int{row, col - 1})
-	} else {
-		queue = append(queue, []int{row, col + 1})
+// Leaves the IDs of every box that needs to move in w.queue, false if any
+// hits a wall
+func (w *warehouse) pushable(id int32, d direction) bool {
+	w.stamp++
+	if w.stamp == 0 {
+		// Wrapped around so old stamps could look current, start again
+		clear(w.seen)
+		w.stamp++
 	}
+	w.queue = append(w.queue[:0], id)
+	w.seen[id] = w.stamp
+	for i := 0; i < len(w.queue); i++ {
+		for _, cell := range w.boxes[w.queue[i]].cells {
+			nextRow, nextCol := cell/w.g.width+d.rowOffset, cell%w.g.width+d.colOffset
+			if !w.g.onGrid(nextRow, nextCol) {
+				return false
+			}
+			next := nextRow*w.g.width + nextCol
+			if w.g.cells[next] == WALL {
+				// Can't move anything...
+				return false
+			}
+			other := w.boxIDs[next]
+			if other == NO_BOX || w.seen[other] == w.stamp {
+				continue
+			}
+			w.seen[other] = w.stamp
+			w.queue = append(w.queue, other)
+		}
+	}
+	return true
+}
 
-	for len(queue) > 0 {
-		curr := queue[0]
-		queue = queue[1:]
-		row := curr[0]
-		col := curr[1]
-		targetKey := fmt.Sprintf("%d-%d", row, col)
+func (w *warehouse) setCell(cell int, value byte) {
+	if w.recording {
+		w.changes = append(w.changes, cellChange{cell, w.g.cells[cell], value})
+	}
+	w.g.cells[cell] = value
+}
 
-		if _, ok := visitedMap[targetKey]; ok {
-			// We've already visited here!
-			continue
+// Lifts every box off the grid before putting them back one step along,
+// otherwise a box could overwrite one that hasn't moved yet
+func (w *warehouse) moveBoxes(ids []int32, d direction) {
+	offset := d.rowOffset*w.g.width + d.colOffset
+	for _, id := range ids {
+		for _, cell := range w.boxes[id].cells {
+			w.setCell(cell, EMPTY)
+			w.boxIDs[cell] = NO_BOX
+		}
+	}
+	for _, id := range ids {
+		b := w.boxes[id]
+		for i := range b.cells {
+			b.cells[i] += offset
+			w.setCell(b.cells[i], b.values[i])
+			w.boxIDs[b.cells[i]] = id
 		}
+	}
+	if w.recording {
+		// Every anchor moves by the same amount
+		w.scoreDelta += len(ids) * (100*d.rowOffset + d.colOffset)
+		for _, id := range ids {
+			w.movedCells = append(w.movedCells, w.boxes[id].cells...)
+		}
+	}
+}
 
-		visitedMap[targetKey] = struct{}{}
-		visited = append(visited, curr)
+func (w *warehouse) processInstruction(dir rune) {
+	direction, ok := directionMap[dir]
+	if !ok {
+		return
+	}
 
-		nextRow, nextCol := row+d.rowOffset, col+d.colOffset
+	robotRow, robotCol := w.robotLocation()
+	nextRow := robotRow + direction.rowOffset
+	nextCol := robotCol + direction.colOffset
 
-		if g[nextRow][nextCol] == WALL {
-			// Can't move anything...
-			return false
-		}
+	if !w.g.onGrid(nextRow, nextCol) {
+		// Robot hasn't moved as move isn't valid move. Either not on grid or it's a wall...
+		return
+	}
+	next := nextRow*w.g.width + nextCol
+	if w.g.cells[next] == WALL {
+		return
+	}
 
-		if g[nextRow][nextCol] == EMPTY {
-			// nothing special to do here we will move at the end and keep searching
-			continue
+	if id := w.boxIDs[next]; id != NO_BOX {
+		if !w.pushable(id, direction) {
+			// robot didn't move!
+			return
 		}
+		w.moveBoxes(w.queue, direction)
+	}
 
-		// If the next node is another part of a box add the box above or below to the list and we carry on!
-		if g[nextRow][nextCol] == DOUBLE_BOX_LEFT {
-			queue = append(queue, []int{nextRow, nextCol})
-			queue = append(queue, []int{nextRow, nextCol + 1})
-		}
+	w.setCell(w.robot, EMPTY)
+	w.setCell(next, ROBOT)
+	w.robot = next
+}
 
-		if g[nextRow][nextCol] == DOUBLE_BOX_RIGHT {
-			queue = append(queue, []int{nextRow, nextCol})
-			queue = append(queue, []int{nextRow, nextCol - 1})
-		}
+// Exits rather than returning the error so the parts can stay as plain ints
+func loadWarehouse(lines []string) *warehouse {
+	w, err := newWarehouse(lines, boxShapes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	return w
+}
 
-	// BFS stored everything it visited now move them in reverse order to prevent weird swapping stuff, essentially we move
-	// the empty spaces not the boxes.
-	// variables are a bit messed up here, should have used a struct with proper names. horrible debugging statements
-	for i := len(visited) - 1; i >= 0; i-- {
-		visitedLocation := visited[i]
-		r, c := visitedLocation[1]+d.colOffset, visitedLocation[0]+d.rowOffset
-		// fmt.Println(visitedLocation[1], visitedLocation[0])
-		// fmt.Println(r, c)
-		// fmt.Printf("%d, %d := %d+direction.X, %d+direction.Y\n", r, c, visitedLocation[1], visitedLocation[0])
-		// fmt.Printf("g[%d][%d] = g[%d][%d]\n", c, r, visitedLocation[0], visitedLocation[1])
-		g[c][r] = g[visitedLocation[0]][visitedLocation[1]]
-		g[visitedLocation[0]][visitedLocation[1]] = EMPTY
+func (w *warehouse) run(instructions string) int {
+	for _, dir := range instructions {
+		w.processInstruction(dir)
 	}
+	return w.score()
+}
 
-	return true
+func partOne() int {
+	return loadWarehouse(gridInput).run(instructions)
 }
 
-func moveDoubleBoxes(d direction, row, col int, grid [][]string) bool {
+// Everything except the robot is twice as wide in part two
+func widen(lines []string) []string {
+	wide := []string{}
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, ".", "..")
+		line = strings.ReplaceAll(line, "#", "##")
+		line = strings.ReplaceAll(line, "@", "@.")
+		line = strings.ReplaceAll(line, "O", "[]")
+		wide = append(wide, line)
+	}
+	return wide
+}
 
-	if d.value == '>' || d.value == '<' {
-		return dfs(d, row, col, grid)
+// Runs every instruction once up front with the warehouse recording what each
+// one changed. The debugger then only ever applies these changes
+func newDebugger(lines []string) *debugger {
+	w := loadWarehouse(lines)
+	score := w.score()
+	d := &debugger{
+		g:          w.g.clone(),
+		startScore: score,
 	}
-	return bfs(d, row, col, grid)
+	d.startRow, d.startCol = w.robotLocation()
+	w.recording = true
+	for _, dir := range instructions {
+		w.changes, w.movedCells, w.scoreDelta = nil, nil, 0
+		w.processInstruction(dir)
+		score += w.scoreDelta
+		record := moveRecord{instruction: dir, score: score, changes: netChanges(w.changes), moved: w.movedCells}
+		record.robotRow, record.robotCol = w.robotLocation()
+		d.history = append(d.history, record)
+	}
+	return d
 }
 
-func processInstruction(dir rune, robotRow, robotCol int, g grid) (int, int) {
-	direction := directionMap[dir]
+// A cell can be set more than once in a move (a box lifted off and another
+// put down on it) so keep one change per cell, from how it was before the
+// move to how it ended up, and drop any that ended up back where they were
+func netChanges(changes []cellChange) []cellChange {
+	net := []cellChange{}
+	for _, change := range changes {
+		merged := false
+		for i := range net {
+			if net[i].cell == change.cell {
+				net[i].after = change.after
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			net = append(net, change)
+		}
+	}
+	result := []cellChange{}
+	for _, change := range net {
+		if change.before != change.after {
+			result = append(result, change)
+		}
+	}
+	return result
+}
 
-	nextRow := robotRow + direction.rowOffset
-	nextCol := robotCol + direction.colOffset
+func (d *debugger) forward() bool {
+	if d.position == len(d.history) {
+		return false
+	}
+	for _, change := range d.history[d.position].changes {
+		d.g.cells[change.cell] = change.after
+	}
+	d.position++
+	return true
+}
 
-	if !g.onGrid(nextRow, nextCol) || g[nextRow][nextCol] == WALL {
-		// Robot hasn't moved as move isn't valid move. Either not on grid or it's a wall...
-		return robotRow, robotCol
-	}
-
-	if g[nextRow][nextCol] == EMPTY {
-		// Swap robot with next empty as it's safe
-		g[robotRow][robotCol] = EMPTY
-		g[nextRow][nextCol] = ROBOT
-		return nextRow, nextCol
-	}
-
-	if g[nextRow][nextCol] == BOX {
-		for {
-			// move along, otherwise were checking ourselves
-			nextRow += direction.rowOffset
-			nextCol += direction.colOffset
-			// Loop until we find a wall or empty. Since we are tracking boxes this is basically the end of the current object run
-			if g[nextRow][nextCol] == EMPTY {
-				// you dont have to move ALL boxes in the chain, only the first
-				// If we find an empty we do the following
-				// 1. Set the robots current [r][c] to '.'
-				// 2. Move the robot 1 unit in current direction, which will override the BOX value at this [r][c]
-				// 3. set the empty [r][c] to be a box
-				// example
-				// @OO.
-				// 1 @OO. -> .OO.
-				// 2 .OO. -> .@O.
-				// 3. .@O. -> .@OO
-				// then return the new grid and where the robot is now at
-				// This can only work for SINGLE width boxes, it's too simple to 2 widths
-				g[robotRow][robotCol] = EMPTY
-				g[robotRow+direction.rowOffset][robotCol+direction.colOffset] = ROBOT
-				g[nextRow][nextCol] = BOX
-				return robotRow + direction.rowOffset, robotCol + direction.colOffset
-			}
+// Undoes in reverse so cells changed more than once end up as they started
+func (d *debugger) back() bool {
+	if d.position == 0 {
+		return false
+	}
+	d.position--
+	changes := d.history[d.position].changes
+	for i := len(changes) - 1; i >= 0; i-- {
+		d.g.cells[changes[i].cell] = changes[i].before
+	}
+	return true
+}
 
-			if g[nextRow][nextCol] == WALL {
-				// Not possible to move, boxes must be up against wall!
-				return robotRow, robotCol
-			}
-		}
+func (d *debugger) jump(move int) {
+	move = max(0, min(move, len(d.history)))
+	for d.position < move && d.forward() {
+	}
+	for d.position > move && d.back() {
 	}
+}
 
-	// Part 2 has double width boxes, bit of a PITA so we need to detect if we are at one of those by testing for either side
-	if g[nextRow][nextCol] == DOUBLE_BOX_LEFT || g[nextRow][nextCol] == DOUBLE_BOX_RIGHT {
-		if !moveDoubleBoxes(direction, nextRow, nextCol, g) {
-			// robot didn't move!
-			return robotRow, robotCol
+func (d *debugger) robotLocation() (int, int) {
+	if d.position == 0 {
+		return d.startRow, d.startCol
+	}
+	last := d.history[d.position-1]
+	return last.robotRow, last.robotCol
+}
+
+func (d *debugger) score() int {
+	if d.position == 0 {
+		return d.startScore
+	}
+	return d.history[d.position-1].score
+}
+
+func (d *debugger) print(w io.Writer) {
+	moved := make(map[int]struct{})
+	if d.position == 0 {
+		fmt.Fprintf(w, "Move 0/%d\n", len(d.history))
+	} else {
+		last := d.history[d.position-1]
+		fmt.Fprintf(w, "Move %d/%d: %c\n", d.position, len(d.history), last.instruction)
+		// Net changes would miss boxes in a row that each moved onto where
+		// the next one was, so highlight every cell of every pushed box
+		for _, cell := range last.moved {
+			moved[cell] = struct{}{}
+		}
+	}
+	var sb strings.Builder
+	for i, v := range d.g.cells {
+		if _, ok := moved[i]; ok {
+			sb.WriteString(HIGHLIGHT_START)
+			sb.WriteByte(v)
+			sb.WriteString(HIGHLIGHT_END)
+		} else {
+			sb.WriteByte(v)
+		}
+		if (i+1)%d.g.width == 0 {
+			sb.WriteByte('\n')
 		}
-		g[nextRow][nextCol] = ROBOT
-		g[robotRow][robotCol] = EMPTY
 	}
+	fmt.Fprint(w, sb.String())
+	row, col := d.robotLocation()
+	fmt.Fprintf(w, "Robot: %d,%d GPS: %d\n\n", row, col, d.score())
+}
 
-	return nextRow, nextCol
+// Plays every move, printing every N of them and the final state
+func (d *debugger) replay(w io.Writer, every int) {
+	d.print(w)
+	for d.forward() {
+		if d.position%every == 0 || d.position == len(d.history) {
+			d.print(w)
+		}
+	}
 }
 
-func partOne() int {
-	grid := createdGrid(gridInput)
-	currentRow, currentCol := grid.robotLocation()
-	for _, dir := range instructions {
-		currentRow, currentCol = processInstruction(dir, currentRow, currentCol, grid)
+// Commands are one per line, counts default to 1
+// n [count]  step forward
+// b [count]  step back
+// j move     jump to move
+// p          print
+// q          quit
+func (d *debugger) run(r io.Reader, w io.Writer) error {
+	d.print(w)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		count := 1
+		if len(fields) > 1 {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				fmt.Fprintf(w, "invalid count %q\n", fields[1])
+				continue
+			}
+			count = n
+		}
+		switch fields[0] {
+		case "n", "next":
+			d.jump(d.position + count)
+		case "b", "back":
+			d.jump(d.position - count)
+		case "j", "jump":
+			d.jump(count)
+		case "p", "print":
+		case "q", "quit":
+			return nil
+		default:
+			fmt.Fprintf(w, "unknown command %q\n", fields[0])
+			continue
+		}
+		d.print(w)
 	}
-	return grid.score()
+	return scanner.Err()
 }
 
-func partTwo() int {
-	partTwoGridInput := []string{}
-	for _, line := range gridInput {
-		line = strings.ReplaceAll(line, ".", "..")
-		line = strings.ReplaceAll(line, "#", "##")
-		line = strings.ReplaceAll(line, "@", "@.")
-		line = strings.ReplaceAll(line, "O", "[]")
-		partTwoGridInput = append(partTwoGridInput, line)
+// stdin is already used for the puzzle so commands come from the terminal
+func debug(lines []string) error {
+	d := newDebugger(lines)
+	if *everyFlag > 0 && !*debugFlag {
+		d.replay(os.Stdout, *everyFlag)
+		return nil
 	}
-	grid := createdGrid(partTwoGridInput)
-	currentRow, currentCol := grid.robotLocation()
-	for _, dir := range instructions {
-		currentRow, currentCol = processInstruction(dir, currentRow, currentCol, grid)
+
+	commandFile := "/dev/tty"
+	if *scriptFlag != "" {
+		commandFile = *scriptFlag
+	}
+	f, err := os.Open(commandFile)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+	return d.run(f, os.Stdout)
+}
 
-	return grid.score()
+func partTwo() int {
+	return loadWarehouse(widen(gridInput)).run(instructions)
 }
 
 func main() {
+	flag.Parse()
+	legend := DEFAULT_LEGEND
+	if *legendFlag != "" {
+		data, err := os.ReadFile(*legendFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		legend = string(data)
+	}
+	var err error
+	if boxShapes, err = parseLegend(legend); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *debugFlag || *scriptFlag != "" || *everyFlag > 0 {
+		lines := gridInput
+		if *wideFlag {
+			lines = widen(gridInput)
+		}
+		if err := debug(lines); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	defer timer()()
+	if *legendFlag != "" {
+		fmt.Println("GPS:", partOne())
+		return
+	}
 	fmt.Println("Part One:", partOne())
 	fmt.Println("Part Two:", partTwo())
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// Square warehouse walled in on every side with a sprinkling of walls and
+// plenty of boxes for the robot, who starts in the middle, to push around
+func randomWarehouse(size, moves int) ([]string, string) {
+	rng := rand.New(rand.NewPCG(2024, 15))
+	lines := []string{}
+	for r := range size {
+		row := make([]byte, size)
+		for c := range row {
+			switch n := rng.IntN(100); {
+			case r == 0 || c == 0 || r == size-1 || c == size-1 || n < 3:
+				row[c] = WALL
+			case n < 30:
+				row[c] = BOX
+			default:
+				row[c] = EMPTY
+			}
+		}
+		if r == size/2 {
+			row[size/2] = ROBOT
+		}
+		lines = append(lines, string(row))
+	}
+
+	dirs := []byte("<>^v")
+	instructions := make([]byte, moves)
+	for i := range instructions {
+		instructions[i] = dirs[rng.IntN(len(dirs))]
+	}
+	return lines, string(instructions)
+}
+
+func benchmarkRun(b *testing.B, lines []string, moves string) {
+	var err error
+	if boxShapes, err = parseLegend(DEFAULT_LEGEND); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		w := loadWarehouse(lines)
+		b.StartTimer()
+		w.run(moves)
+	}
+}
+
+func BenchmarkPartOne(b *testing.B) {
+	lines, moves := randomWarehouse(1000, 1_000_000)
+	benchmarkRun(b, lines, moves)
+}
+
+func BenchmarkPartTwo(b *testing.B) {
+	lines, moves := randomWarehouse(1000, 1_000_000)
+	benchmarkRun(b, widen(lines), moves)
+}
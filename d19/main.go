@@ -2,30 +2,55 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"iter"
+	"math"
+	"math/big"
 	"os"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
-type towel struct {
-	design string
-}
+type (
+	towel struct {
+		design string
+	}
+	// Every towel pattern is a path from the root, end marks that a pattern
+	// finishes on this node so e.g. 'r' and 'rb' share the 'r' node
+	trieNode struct {
+		children map[byte]*trieNode
+		end      bool
+	}
+	// The number of arrangements grows exponentially with design length so
+	// once adding would overflow an int we switch to big
+	arrangements struct {
+		small int
+		big   *big.Int
+	}
+	// minTowels is -1 for impossible designs, buildable is the length of the
+	// longest prefix that can be made and failsAt is the first position that
+	// no towel pattern, from any buildable prefix, gets past
+	designReport struct {
+		design    string
+		minTowels int
+		buildable int
+		failsAt   int
+	}
+)
 
 var (
-	// these are sorted by length as we always want longest match first
 	towelPatterns = []string{}
 	designs       = []string{}
-	designGraph   = map[byte][]string{}
-	resultCache   = map[string]int{}
+	towelTrie     = newTrieNode()
+	listFlag      = flag.Int("list", 0, "print up to this many arrangements for each design")
+	explainFlag   = flag.Bool("explain", false, "print the fewest towels for each possible design and where impossible ones fail")
 )
 
 func init() {
 	scanner := bufio.NewScanner(os.Stdin)
 	lineIdx := 0
-	resultCache = map[string]int{}
-	designGraph = map[byte][]string{}
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if len(strings.TrimSpace(line)) == 0 {
@@ -41,18 +66,8 @@ func init() {
 		lineIdx++
 	}
 
-	// Sort by longest so we get longest match first
-	sort.Slice(towelPatterns, func(i, j int) bool {
-		return len(towelPatterns[i]) > len(towelPatterns[j])
-	})
-
-	// Build a 'graph' of what starting letter can reach which pattern
-	// assume map is map[r] = ['rb', 'r']
-	// design is 'rc'
-	// then check 'rc' starts with something in map[r] in this case in does
 	for _, tp := range towelPatterns {
-		firstLetter := tp[0]
-		designGraph[firstLetter] = append(designGraph[firstLetter], tp)
+		towelTrie.insert(tp)
 	}
 }
 
@@ -63,63 +78,199 @@ func timer() func() {
 	}
 }
 
-// for all possible patterns when we are at a 'target[0]' iterate them (longest first as this is pre sorted)
-// if target starts with a valid possible pattern from 'target[0]' then recursively check by removing the pattern
-// from the string and trying again
-// Can't just do a simple match on prefix since we edit the string there are edge cases that overlap and produce the wrong result
-// for example is valid patterns are ['wrb', 'wr','bx'] and target design is 'wrbx' and char map[w] = ['wrb', 'wr'],  map[b] = ['bx']
-// If we just checked for prefix would we get
-// wrb -> prefix match, check for ('x', tps)
-// b -> prefix match, FAIL as 'b' is not a valid pattern
-// now if we consider all patterns from the first latter of each design/sub design we would get the right answer via
-// wrbx startsWtih wrb, -> target is now 'x' -> no patterns start with 'x'
-// loop back to
-// wrbx startsWith wr -> target is now 'bx'
-// 'bx' startsWith bx -> target is now ”
-// ” so valid combo
-func totalCombinations(design string, tps []string) int {
-	target := strings.Clone(design)
-	result := 0
-
-	//Give this design string we know the result, could be full design or partial
-	if result, ok := resultCache[design]; ok {
-		return result
-	}
-
-	// Since this is a recursive func, if target is "" it means everything was valid
-	if len(target) == 0 {
-		result++
-	} else {
-		// Since we need all possible combos we no longer exit if any design is possible
-		// check for every possible pattern at every possible step
-		// without caching this VERY slow
-		for _, possiblePattern := range designGraph[target[0]] {
-			if strings.HasPrefix(target, possiblePattern) {
-				combos := totalCombinations(target[len(possiblePattern):], tps)
-				result += combos
+func newTrieNode() *trieNode {
+	return &trieNode{children: make(map[byte]*trieNode)}
+}
+
+func (t *trieNode) insert(pattern string) {
+	curr := t
+	for i := range len(pattern) {
+		next, ok := curr.children[pattern[i]]
+		if !ok {
+			next = newTrieNode()
+			curr.children[pattern[i]] = next
+		}
+		curr = next
+	}
+	curr.end = true
+}
+
+// Yields the length of every towel pattern that design[start:] begins with,
+// shortest first. Walking the trie checks all of them in one pass instead of
+// a HasPrefix per pattern
+func (t *trieNode) matches(design string, start int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		curr := t
+		for i := start; i < len(design); i++ {
+			next, ok := curr.children[design[i]]
+			if !ok {
+				return
+			}
+			curr = next
+			if curr.end && !yield(i+1-start) {
+				return
 			}
 		}
 	}
+}
+
+// How far into design a pattern starting at start could get before there are
+// no more towels with that next stripe, len(design) if it never fails
+func (t *trieNode) reach(design string, start int) int {
+	curr := t
+	for i := start; i < len(design); i++ {
+		next, ok := curr.children[design[i]]
+		if !ok {
+			return i
+		}
+		curr = next
+	}
+	return len(design)
+}
+
+func (a arrangements) toBig() *big.Int {
+	if a.big != nil {
+		return a.big
+	}
+	return big.NewInt(int64(a.small))
+}
+
+func (a arrangements) add(o arrangements) arrangements {
+	if a.big == nil && o.big == nil && a.small <= math.MaxInt-o.small {
+		return arrangements{small: a.small + o.small}
+	}
+	return arrangements{big: new(big.Int).Add(a.toBig(), o.toBig())}
+}
+
+func (a arrangements) possible() bool {
+	return a.big != nil || a.small > 0
+}
+
+func (a arrangements) String() string {
+	if a.big != nil {
+		return a.big.String()
+	}
+	return strconv.Itoa(a.small)
+}
+
+// ways[i] is how many arrangements of towels make design[i:], working back
+// from the end of the design where there is exactly one way to make ""
+// Any pattern matching at i adds all the ways of making what's left after it
+// for example is valid patterns are ['wrb', 'wr','bx'] and target design is 'wrbx'
+// ways[4] = 1 for the empty string
+// ways[3] = 0 nothing matches 'x'
+// ways[2] = ways[4] = 1 'bx' matches
+// ways[1] = 0 nothing starts with 'r'
+// ways[0] = ways[3] + ways[2] = 1 both 'wrb' and 'wr' match but only 'wr' leaves something possible
+func countArrangements(design string) []arrangements {
+	ways := make([]arrangements, len(design)+1)
+	ways[len(design)] = arrangements{small: 1}
+	for i := len(design) - 1; i >= 0; i-- {
+		for length := range towelTrie.matches(design, i) {
+			ways[i] = ways[i].add(ways[i+length])
+		}
+	}
+	return ways
+}
 
-	resultCache[design] = result
+// Up to limit of the actual arrangements for design, ways is used to skip
+// any pattern that leaves a remainder which can't be made
+func listArrangements(design string, ways []arrangements, limit int) [][]string {
+	result := [][]string{}
+	var walk func(start int, current []string)
+	walk = func(start int, current []string) {
+		if start == len(design) {
+			result = append(result, append([]string{}, current...))
+			return
+		}
+		for length := range towelTrie.matches(design, start) {
+			if len(result) >= limit {
+				return
+			}
+			if ways[start+length].possible() {
+				walk(start+length, append(current, design[start:start+length]))
+			}
+		}
+	}
+	if limit > 0 && ways[0].possible() {
+		walk(0, []string{})
+	}
 	return result
 }
 
-func bothParts() (int, int) {
+// The fewest towels needed works the same way as counting from the back,
+// taking the min instead of the sum. Buildable prefixes work from the front,
+// any position a pattern ends on from an already buildable one is buildable
+func explainDesign(design string, ways []arrangements) designReport {
+	report := designReport{design: design, minTowels: -1}
+
+	if ways[0].possible() {
+		fewest := make([]int, len(design)+1)
+		for i := len(design) - 1; i >= 0; i-- {
+			fewest[i] = -1
+			for length := range towelTrie.matches(design, i) {
+				if rest := fewest[i+length]; rest != -1 && (fewest[i] == -1 || rest+1 < fewest[i]) {
+					fewest[i] = rest + 1
+				}
+			}
+		}
+		report.minTowels = fewest[0]
+		report.buildable = len(design)
+		report.failsAt = len(design)
+		return report
+	}
+
+	buildable := make([]bool, len(design)+1)
+	buildable[0] = true
+	for i := range len(design) {
+		if !buildable[i] {
+			continue
+		}
+		report.buildable = i
+		report.failsAt = max(report.failsAt, towelTrie.reach(design, i))
+		for length := range towelTrie.matches(design, i) {
+			buildable[i+length] = true
+		}
+	}
+	return report
+}
+
+func (r designReport) String() string {
+	if r.minTowels != -1 {
+		return fmt.Sprintf("%s: possible with %d towels", r.design, r.minTowels)
+	}
+	prefix := r.design[:r.buildable]
+	// Patterns can match all the way to the end and still need more stripes
+	if r.failsAt == len(r.design) {
+		return fmt.Sprintf("%s: impossible, longest buildable prefix is %q, every pattern runs past the end", r.design, prefix)
+	}
+	return fmt.Sprintf("%s: impossible, longest buildable prefix is %q, every pattern fails at %d (%q)",
+		r.design, prefix, r.failsAt, r.design[r.failsAt])
+}
+
+func bothParts() (int, arrangements) {
 	possibleDesigns := 0
-	possibleCombinations := 0
+	possibleCombinations := arrangements{}
 
 	for _, design := range designs {
-		result := totalCombinations(design, towelPatterns)
-		if result > 0 {
+		ways := countArrangements(design)
+		if ways[0].possible() {
 			possibleDesigns++
 		}
-		possibleCombinations += result
+		possibleCombinations = possibleCombinations.add(ways[0])
+
+		for _, arrangement := range listArrangements(design, ways, *listFlag) {
+			fmt.Printf("%s: %s\n", design, strings.Join(arrangement, ","))
+		}
+		if *explainFlag {
+			fmt.Println(explainDesign(design, ways))
+		}
 	}
 	return possibleDesigns, possibleCombinations
 }
 
 func main() {
+	flag.Parse()
 	defer timer()()
 	partOne, partTwo := bothParts()
 	fmt.Println("Part One:", partOne)
@@ -2,9 +2,14 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"math"
+	"math/big"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -15,7 +20,30 @@ type (
 		sequence    string
 		complexity  int
 	}
-	keypad [][]string
+	keypad struct {
+		rows    [][]string
+		buttons map[string][2]int
+		paths   map[searchKey][]string
+	}
+	// The first keypad is the one the code is typed on, every keypad after that
+	// is the one used to drive the robot in front of it. We (the human) press
+	// the buttons that drive the last robot so only the length of that matters
+	keypadChain struct {
+		pads []*keypad
+		// costs[robotDepth][from][to] is how many presses it takes the human to
+		// move the arm on that keypad from one control to another and press it.
+		// There is one more layer than there are keypads, the human's, where
+		// every press costs exactly 1
+		costs []costTable
+	}
+	costTable [NUM_CONTROLS][NUM_CONTROLS]pressCount
+	// Press counts grow by roughly 2.5x per robot so after ~45 robots they no
+	// longer fit in an int. The int is used until an operation would overflow,
+	// only then does it switch over to big
+	pressCount struct {
+		small int
+		big   *big.Int
+	}
 	button struct {
 		y     int
 		x     int
@@ -36,10 +64,6 @@ type (
 		x    int
 		path string
 	}
-	pathKey struct {
-		seq        string
-		robotDepth int
-	}
 	searchKey struct {
 		start, end string
 	}
@@ -47,59 +71,298 @@ type (
 
 const (
 	EMPTY = ' '
+	// Marks a gap in the keypad text format since spaces are easy to lose
+	GAP          = '#'
+	START_BUTTON = "A"
+	// The buttons a robot can be told to press, indexed by position in here
+	CONTROLS     = START_BUTTON + "^v<>"
+	NUM_CONTROLS = len(CONTROLS)
+	// Sequences grow by roughly 2.5x per robot so only rebuild the actual
+	// button presses when the result is going to be printable
+	MAX_SEQUENCE_LENGTH = 1 << 20
 )
 
 var (
 	codeSequences = []*codeSequence{}
-	numberPanel   = keypad{
+	numberPanel   = newKeypad([][]string{
 		{"7", "8", "9"},
 		{"4", "5", "6"},
 		{"1", "2", "3"},
 		{" ", "0", "A"},
-	}
-	directionPanel = keypad{
+	})
+	directionPanel = newKeypad([][]string{
 		{" ", "^", "A"},
 		{"<", "v", ">"},
-	}
+	})
 	directionMap = map[rune]direction{
 		'>': {0, 1, '>'},
 		'v': {1, 0, 'v'},
 		'<': {0, -1, '<'},
 		'^': {-1, 0, '^'},
 	}
-	instructionCache = make(map[pathKey]int)
-	pathsCache       = make(map[searchKey][]string)
+	keypadFile   = flag.String("keypads", "", "file describing a custom keypad chain, see parseKeypadChain")
+	showSequence = flag.Bool("sequence", false, "rebuild and verify one shortest button sequence per code")
+	showLayers   = flag.Bool("layers", false, "with -sequence, print the sequence typed on every keypad in the chain")
+	robots       = flag.Int("robots", 2, "number of direction keypad robots, replaces the two parts when set")
 )
 
-func (k keypad) findValue(v string) (int, int) {
-	for y, row := range k {
+func newKeypad(rows [][]string) *keypad {
+	buttons := make(map[string][2]int)
+	for y, row := range rows {
 		for x, value := range row {
-			if value == v {
-				return y, x
+			if value != string(EMPTY) {
+				buttons[value] = [2]int{y, x}
 			}
 		}
 	}
-	return -1, -1
+	return &keypad{
+		rows:    rows,
+		buttons: buttons,
+		paths:   make(map[searchKey][]string),
+	}
 }
 
-func (k keypad) shortestPaths(start, end string) []string {
-	startY, startX := k.findValue(start)
-	blankY, blankX := k.findValue(" ")
-	endY, endX := k.findValue(end)
+// Parses a single keypad from its text form, one row per line and one
+// label per character, e.g. the number panel is
+//
+//	789
+//	456
+//	123
+//	#0A
+//
+// where # (or a space) is a gap the robot arm is not allowed over
+func parseKeypad(lines []string) (*keypad, error) {
+	rows := [][]string{}
+	seen := make(map[string]struct{})
+	for _, line := range lines {
+		row := []string{}
+		for _, char := range line {
+			if char == GAP || char == EMPTY {
+				row = append(row, string(EMPTY))
+				continue
+			}
+			label := string(char)
+			if _, dupe := seen[label]; dupe {
+				return nil, fmt.Errorf("duplicate button %q", label)
+			}
+			seen[label] = struct{}{}
+			row = append(row, label)
+		}
+		rows = append(rows, row)
+	}
+	if _, ok := seen[START_BUTTON]; !ok {
+		return nil, fmt.Errorf("keypad has no %q button to start from", START_BUTTON)
+	}
+	return newKeypad(rows), nil
+}
+
+// Keypads are listed in chain order (the door keypad first) and separated by
+// blank lines. A keypad can be prefixed with a "*N" line to repeat it N times
+// so the part two chain doesn't need 25 copies of the direction panel, e.g.
+//
+//	789
+//	456
+//	123
+//	#0A
+//
+//	*25
+//	#^A
+//	<v>
+func parseKeypadChain(text string) (*keypadChain, error) {
+	pads := []*keypad{}
+	blocks := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n")
+	for _, block := range blocks {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if len(lines) == 0 || lines[0] == "" {
+			continue
+		}
+		repeat := 1
+		if strings.HasPrefix(lines[0], "*") {
+			n, err := strconv.Atoi(lines[0][1:])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid repeat %q", lines[0])
+			}
+			repeat = n
+			lines = lines[1:]
+		}
+		pad, err := parseKeypad(lines)
+		if err != nil {
+			return nil, err
+		}
+		for range repeat {
+			pads = append(pads, pad)
+		}
+	}
+	return newKeypadChain(pads...)
+}
 
+func loadKeypadChain(filename string) (*keypadChain, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeypadChain(string(data))
+}
+
+// Every keypad after the first is pressed to move the robot arm in front of it
+// so it must have all of the direction buttons as well as A, and each of them
+// has to be reachable from the others
+func newKeypadChain(pads ...*keypad) (*keypadChain, error) {
+	if len(pads) == 0 {
+		return nil, errors.New("keypad chain is empty")
+	}
+	controls := []string{START_BUTTON}
+	for char := range directionMap {
+		controls = append(controls, string(char))
+	}
+	for i, pad := range pads[1:] {
+		for _, from := range controls {
+			for _, to := range controls {
+				if len(pad.shortestPaths(from, to)) == 0 {
+					return nil, fmt.Errorf("keypad %d cannot move from %q to %q", i+1, from, to)
+				}
+			}
+		}
+	}
+	chain := &keypadChain{pads: pads}
+	chain.buildCostTable()
+	return chain, nil
+}
+
+// Works up from the human's keypad (every press costs 1) to the first robot
+// keypad. Moving from one control to another on a keypad means taking one of
+// the shortest paths between them, where each path is itself a sequence typed
+// on the next keypad up, so its cost comes from the layer before
+func (c *keypadChain) buildCostTable() {
+	c.costs = make([]costTable, len(c.pads)+1)
+	for from := range NUM_CONTROLS {
+		for to := range NUM_CONTROLS {
+			c.costs[len(c.pads)][from][to] = pressCount{small: 1}
+		}
+	}
+	for robotDepth := len(c.pads) - 1; robotDepth >= 1; robotDepth-- {
+		for from := range NUM_CONTROLS {
+			for to := range NUM_CONTROLS {
+				c.costs[robotDepth][from][to] = c.pathCost(CONTROLS[from:from+1], CONTROLS[to:to+1], robotDepth)
+			}
+		}
+	}
+}
+
+// Cheapest way of moving the arm on keypad robotDepth from one button to
+// another and pressing it. Robot keypads come straight from the cost table,
+// the door keypad can have any buttons so it has to check its paths
+func (c *keypadChain) moveCost(from, to string, robotDepth int) pressCount {
+	if robotDepth > 0 {
+		return c.costs[robotDepth][strings.Index(CONTROLS, from)][strings.Index(CONTROLS, to)]
+	}
+	return c.pathCost(from, to, robotDepth)
+}
+
+func (c *keypadChain) pathCost(from, to string, robotDepth int) pressCount {
+	// it's possible paths can return multiple paths of the same length
+	// e.g. [^^>A, v>^A ]
+	// so for each of these we need to check the cost of actually doing this for all robots in the chain
+	// for example, it might be faster for robot 1 but much slower for robot 1+X so we get the minimum length
+	paths := c.pads[robotDepth].shortestPaths(from, to)
+	best := c.seqCost(paths[0], robotDepth+1)
+	for _, subSequence := range paths[1:] {
+		if cost := c.seqCost(subSequence, robotDepth+1); cost.less(best) {
+			best = cost
+		}
+	}
+	return best
+}
+
+// Total presses the human needs for seq to be typed on keypad robotDepth
+func (c *keypadChain) seqCost(seq string, robotDepth int) pressCount {
+	result := pressCount{}
+	currentlyAt := START_BUTTON
+	for _, character := range seq {
+		charAsString := string(character)
+		result = result.add(c.moveCost(currentlyAt, charAsString, robotDepth))
+		currentlyAt = charAsString
+	}
+	return result
+}
+
+func (p pressCount) toBig() *big.Int {
+	if p.big != nil {
+		return p.big
+	}
+	return big.NewInt(int64(p.small))
+}
+
+// Counts are never negative so we only need to check the top end
+func (p pressCount) add(o pressCount) pressCount {
+	if p.big == nil && o.big == nil && p.small <= math.MaxInt-o.small {
+		return pressCount{small: p.small + o.small}
+	}
+	return pressCount{big: new(big.Int).Add(p.toBig(), o.toBig())}
+}
+
+func (p pressCount) mul(n int) pressCount {
+	if p.big == nil && (n == 0 || p.small <= math.MaxInt/n) {
+		return pressCount{small: p.small * n}
+	}
+	return pressCount{big: new(big.Int).Mul(p.toBig(), big.NewInt(int64(n)))}
+}
+
+func (p pressCount) less(o pressCount) bool {
+	if p.big == nil && o.big == nil {
+		return p.small < o.small
+	}
+	return p.toBig().Cmp(o.toBig()) < 0
+}
+
+func (p pressCount) String() string {
+	if p.big != nil {
+		return p.big.String()
+	}
+	return strconv.Itoa(p.small)
+}
+
+// The puzzle's chain, the number panel followed by numberRobots direction panels
+func defaultChain(numberRobots int) *keypadChain {
+	pads := []*keypad{numberPanel}
+	for range numberRobots {
+		pads = append(pads, directionPanel)
+	}
+	chain, _ := newKeypadChain(pads...)
+	return chain
+}
+
+func (k *keypad) findValue(v string) (int, int) {
+	if pos, ok := k.buttons[v]; ok {
+		return pos[0], pos[1]
+	}
+	return -1, -1
+}
+
+func (k *keypad) shortestPaths(start, end string) []string {
 	key := searchKey{start, end}
 
-	if prev, seen := pathsCache[key]; seen {
+	if prev, seen := k.paths[key]; seen {
 		return prev
 	}
 
+	startY, startX := k.findValue(start)
+	endY, endX := k.findValue(end)
+
 	paths := []string{}
+	if startY == -1 || endY == -1 {
+		k.paths[key] = paths
+		return paths
+	}
+
 	queue := []point{
 		{y: startY, x: startX, path: ""},
 	}
-	visited := make(map[[2]int]struct{})
-	visited[[2]int{startY, startX}] = struct{}{}
-	visited[[2]int{blankY, blankX}] = struct{}{}
+	visited := make([][]bool, len(k.rows))
+	for y, row := range k.rows {
+		visited[y] = make([]bool, len(row))
+	}
+	visited[startY][startX] = true
 
 	shortestPathLength := math.MaxInt
 	currentDist := 0
@@ -119,8 +382,12 @@ func (k keypad) shortestPaths(start, end string) []string {
 			nextY := curr.y + direction.yOffset
 			nextX := curr.x + direction.xOffset
 
-			if nextY >= 0 && nextY < len(k) && nextX >= 0 && nextX < len(k[nextY]) {
-				if _, seen := visited[[2]int{nextY, nextX}]; !seen {
+			if nextY >= 0 && nextY < len(k.rows) && nextX >= 0 && nextX < len(k.rows[nextY]) {
+				// Custom keypads can have more than one gap
+				if k.rows[nextY][nextX] == string(EMPTY) {
+					continue
+				}
+				if !visited[nextY][nextX] {
 					newPoint := point{
 						y:    nextY,
 						x:    nextX,
@@ -131,14 +398,10 @@ func (k keypad) shortestPaths(start, end string) []string {
 			}
 		}
 
-		visited[[2]int{curr.y, curr.x}] = struct{}{}
+		visited[curr.y][curr.x] = true
 	}
 
-	pathsCache[key] = paths
-
-	for k2 := range visited {
-		delete(visited, k2)
-	}
+	k.paths[key] = paths
 
 	return paths
 }
@@ -165,88 +428,175 @@ func timer() func() {
 	}
 }
 
-func min(s []int) int {
-	min := s[0]
-	for _, v := range s[1:] {
-		if v < min {
-			min = v
+// Makes sure every button in the code exists on the first keypad and can be
+// reached, otherwise there are no paths to take the min of
+func (c *keypadChain) validate(code string) error {
+	pad := c.pads[0]
+	currentlyAt := START_BUTTON
+	for _, character := range code {
+		charAsString := string(character)
+		if len(pad.shortestPaths(currentlyAt, charAsString)) == 0 {
+			return fmt.Errorf("code %s: button %q cannot be reached from %q", code, charAsString, currentlyAt)
 		}
+		currentlyAt = charAsString
 	}
-	return min
+	return nil
 }
 
-// Returns Ints instead of the actual string due to the number of calc
-// strings take waayyyyy too long
-// Number of robots is how many robots there are in play
-// part 1 has 2 robots, part 2 has 25
-func minLengthOpDirPanel(seq string, numberRobots int) int {
-	if numberRobots == 0 {
-		return len(seq)
-	}
-
-	key := pathKey{seq, numberRobots}
+// This runs on the door keypad to give the first set of opts, it will return
+// something like <A^A>^^AvvvA
+// this is then passed down the rest of the chain recursively to get the full list
+func (cs *codeSequence) calcSequence(chain *keypadChain) pressCount {
+	return chain.seqCost(cs.code, 0)
+}
 
-	if s, known := instructionCache[key]; known {
-		return s
+// Rebuilds one concrete shortest sequence for every keypad in the chain using
+// the costs already in the chain's cost table. layers[0] is the code and the
+// last layer is what the human presses, ties are broken alphabetically so the
+// output is stable between runs
+func (c *keypadChain) reconstruct(code string) ([]string, error) {
+	if length := c.seqCost(code, 0); length.big != nil || length.small > MAX_SEQUENCE_LENGTH {
+		return nil, fmt.Errorf("code %s: sequence of %v presses is too long to build", code, length)
 	}
 
-	result := 0
-	currentlyAt := "A"
-	for _, character := range seq {
-		charAsString := string(character)
-		paths := directionPanel.shortestPaths(currentlyAt, charAsString)
-		possibleOptions := []int{}
-		for _, subSequence := range paths {
-			possibleOptions = append(possibleOptions, minLengthOpDirPanel(subSequence, numberRobots-1))
+	layers := []string{code}
+	seq := code
+	for robotDepth, pad := range c.pads {
+		var sb strings.Builder
+		currentlyAt := START_BUTTON
+		for _, character := range seq {
+			charAsString := string(character)
+			best := ""
+			bestLength := pressCount{small: math.MaxInt}
+			for _, subSequence := range pad.shortestPaths(currentlyAt, charAsString) {
+				length := c.seqCost(subSequence, robotDepth+1)
+				if length.less(bestLength) || (!bestLength.less(length) && subSequence < best) {
+					best = subSequence
+					bestLength = length
+				}
+			}
+			sb.WriteString(best)
+			currentlyAt = charAsString
 		}
-		result += min(possibleOptions)
-		currentlyAt = charAsString
+		seq = sb.String()
+		layers = append(layers, seq)
 	}
-	instructionCache[key] = result
-	return result
+	return layers, nil
 }
 
-// This runs on the numeric part to give the first set of opts, it will return
-// something like <A^A>^^AvvvA
-// this is then passed to the directional button recursively to get the full list
-// num robots is only used on the dir board not the numerical one
-func (cs *codeSequence) calcSequence(seq string, numberRobots int) int {
-	result := 0
-	currentlyAt := "A"
-	for _, character := range seq {
-		charAsString := string(character)
-		paths := numberPanel.shortestPaths(currentlyAt, charAsString)
-		// it's possible paths can return multiple paths of the same length
-		// e.g. [^^>A, v>^A ]
-		// so for each of these we need to check the cost of actually doing this, recursively for all robots in the chain
-		// for example, it might be faster for robot 1 but much slower for robot 1+X so we get the minimum length
-		possibleOptions := []int{}
-		for _, subSequence := range paths {
-			possibleOptions = append(possibleOptions, minLengthOpDirPanel(subSequence, numberRobots))
-		}
-		result += min(possibleOptions)
-		currentlyAt = charAsString
+// Plays the human's presses through every robot in the chain and returns
+// what ends up typed on the door keypad. Each robot starts pointing at A and
+// errors if it is ever moved off its keypad or over a gap
+func (c *keypadChain) simulate(presses string) (string, error) {
+	for robotDepth := len(c.pads) - 1; robotDepth >= 0; robotDepth-- {
+		pad := c.pads[robotDepth]
+		y, x := pad.findValue(START_BUTTON)
+		var sb strings.Builder
+		for i, character := range presses {
+			if string(character) == START_BUTTON {
+				sb.WriteString(pad.rows[y][x])
+				continue
+			}
+			direction, ok := directionMap[character]
+			if !ok {
+				return "", fmt.Errorf("keypad %d: unknown button %q at press %d", robotDepth, character, i)
+			}
+			y += direction.yOffset
+			x += direction.xOffset
+			if y < 0 || y >= len(pad.rows) || x < 0 || x >= len(pad.rows[y]) || pad.rows[y][x] == string(EMPTY) {
+				return "", fmt.Errorf("keypad %d: robot arm left the buttons at press %d", robotDepth, i)
+			}
+		}
+		presses = sb.String()
 	}
-	return result
+	return presses, nil
 }
 
-func partOne() int {
-	result := 0
-	for _, seq := range codeSequences {
-		result += seq.calcSequence(seq.code, 2) * seq.numericPart
+// Fills in the sequence for each code and checks that pressing it really does
+// type the code, the lengths must also match what the cost table worked out
+func printSequences(chain *keypadChain) error {
+	for _, cs := range codeSequences {
+		layers, err := chain.reconstruct(cs.code)
+		if err != nil {
+			return err
+		}
+		cs.sequence = layers[len(layers)-1]
+		cs.complexity = len(cs.sequence) * cs.numericPart
+
+		typed, err := chain.simulate(cs.sequence)
+		if err != nil {
+			return fmt.Errorf("code %s: %w", cs.code, err)
+		}
+		if typed != cs.code {
+			return fmt.Errorf("code %s: sequence types %s instead", cs.code, typed)
+		}
+		if expected := cs.calcSequence(chain); expected.big != nil || len(cs.sequence) != expected.small {
+			return fmt.Errorf("code %s: sequence has %d presses, expected %v", cs.code, len(cs.sequence), expected)
+		}
+
+		if *showLayers {
+			for i := len(layers) - 1; i >= 0; i-- {
+				fmt.Println(layers[i])
+			}
+			fmt.Println()
+			continue
+		}
+		fmt.Printf("%s: %s\n", cs.code, cs.sequence)
 	}
-	return result
+	return nil
 }
 
-func partTwo() int {
-	result := 0
+func complexity(chain *keypadChain) pressCount {
+	result := pressCount{}
 	for _, seq := range codeSequences {
-		result += seq.calcSequence(seq.code, 25) * seq.numericPart
+		result = result.add(seq.calcSequence(chain).mul(seq.numericPart))
 	}
 	return result
 }
+
+func partOne() pressCount {
+	return complexity(defaultChain(2))
+}
+
+func partTwo() pressCount {
+	return complexity(defaultChain(25))
+}
+
 func main() {
+	flag.Parse()
 	defer timer()()
+	var chain *keypadChain
+	if *keypadFile != "" {
+		var err error
+		chain, err = loadKeypadChain(*keypadFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, seq := range codeSequences {
+			if err := chain.validate(seq.code); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
+	robotsSet := false
+	flag.Visit(func(f *flag.Flag) {
+		robotsSet = robotsSet || f.Name == "robots"
+	})
+	if chain == nil && (robotsSet || *showSequence) {
+		chain = defaultChain(*robots)
+	}
+	if *showSequence {
+		if err := printSequences(chain); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if chain != nil {
+		fmt.Println("Complexity:", complexity(chain))
+		return
+	}
 	fmt.Println("Part One:", partOne())
 	fmt.Println("Part Two:", partTwo())
 
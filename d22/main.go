@@ -2,18 +2,38 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"iter"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+type (
+	// Each step of calc only shifts and xors bits of a 24 bit number, which
+	// makes it a linear map over GF(2). Column i is what bit i turns into
+	bitMatrix [SECRET_BITS]uint32
+)
+
 var (
-	input []int
+	input        []int
+	nthFlag      = flag.Int("nth", -1, "print the nth secret for every buyer")
+	sequenceFlag = flag.String("sequence", "", "report when each buyer sells for a run of 4 changes, e.g. -2,1,-1,3")
 )
 
 const (
 	PRUNE_MODULUS = 16777216
+	SECRET_BITS   = 24
+	NUM_SECRETS   = 2000
+	// A price is a single digit so a change is always between -9 and 9
+	DELTA_RANGE = 19
+	// Every possible run of 4 changes, each run is stored as a base 19 number
+	// so it can index straight into a slice instead of hashing a [4]int
+	SEQUENCE_COUNT = DELTA_RANGE * DELTA_RANGE * DELTA_RANGE * DELTA_RANGE
 )
 
 func init() {
@@ -45,18 +65,81 @@ func calc(secret int) int {
 	return secret
 }
 
+// Yields the price and the change from the previous price for each of the
+// next n secrets, the starting secret only sets the first previous price
+func priceChanges(secret, n int) iter.Seq2[int, int] {
+	return func(yield func(int, int) bool) {
+		// % 10 will get the last digit
+		currentPrice := secret % 10
+		for range n {
+			secret = calc(secret)
+			newPrice := secret % 10
+			if !yield(newPrice, newPrice-currentPrice) {
+				return
+			}
+			currentPrice = newPrice
+		}
+	}
+}
+
+// prices[0] is the price of the starting secret, deltas[i] is the change that
+// got us to prices[i+1]
 func generateDeltas(secret int) ([]int, []int) {
-	subSecrets := []int{}
+	prices := []int{secret % 10}
 	deltas := []int{}
-	subSecrets = append(subSecrets, secret)
-	for i := 1; i <= 2000; i++ {
-		secret = calc(secret)
-		// % 10 will get the last digit
-		lastDigit := secret % 10
-		subSecrets = append(subSecrets, lastDigit)
-		deltas = append(deltas, lastDigit-deltas[i-1])
+	for price, delta := range priceChanges(secret, NUM_SECRETS) {
+		prices = append(prices, price)
+		deltas = append(deltas, delta)
+	}
+	return prices, deltas
+}
+
+// Builds the matrix for a single call of calc by running each bit through it
+func calcMatrix() bitMatrix {
+	m := bitMatrix{}
+	for i := range SECRET_BITS {
+		m[i] = uint32(calc(1 << i))
 	}
-	return subSecrets, deltas
+	return m
+}
+
+func (m bitMatrix) apply(v uint32) uint32 {
+	result := uint32(0)
+	for i := 0; v != 0; i, v = i+1, v>>1 {
+		if v&1 == 1 {
+			result ^= m[i]
+		}
+	}
+	return result
+}
+
+// Returns the matrix for applying o then m
+func (m bitMatrix) compose(o bitMatrix) bitMatrix {
+	result := bitMatrix{}
+	for i, column := range o {
+		result[i] = m.apply(column)
+	}
+	return result
+}
+
+// Skips straight to the nth secret by raising the calc matrix to the nth power
+// with square and multiply, so it's log(n) matrix products instead of n calcs
+func nthSecret(secret, n int) int {
+	if n == 0 {
+		return secret
+	}
+	result := bitMatrix{}
+	for i := range SECRET_BITS {
+		result[i] = 1 << i
+	}
+	power := calcMatrix()
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result = result.compose(power)
+		}
+		power = power.compose(power)
+	}
+	return int(result.apply(uint32(secret % PRUNE_MODULUS)))
 }
 
 func partOne() int {
@@ -70,60 +153,154 @@ func partOne() int {
 	return result
 }
 
-func partTwo() int {
-	maxPossibleBanana := -1
-	secretBananaCounts := map[[4]int]int{}
-	for _, secretNum := range input {
-		deltas := make([]int, 2000)
+// Turns an encoded run of changes back into the changes themselves
+func decodeSequence(key int) [4]int {
+	seq := [4]int{}
+	for i := 3; i >= 0; i-- {
+		seq[i] = key%DELTA_RANGE - 9
+		key /= DELTA_RANGE
+	}
+	return seq
+}
+
+// Adds the bananas for every buyer in secrets into totals. Rather than a fresh
+// map per buyer, seen records which buyer last sold on each sequence, so we
+// only count the first time a buyer sees it and never need to clear it
+func sellBananas(secrets []int, totals []int) {
+	seen := make([]int32, SEQUENCE_COUNT)
+	for buyer, secretNum := range secrets {
+		stamp := int32(buyer + 1)
 		// Last digit of the current 'step' in the secret process
 		// as each price is the last digit of each generated secret
 		// This is the 'first' price
 		currentPrice := secretNum % 10
+		key := 0
 
-		changeSeq := map[[4]int]int{}
-
-		for i := range 2000 {
+		for i := range NUM_SECRETS {
 			secretNum = calc(secretNum)
 			// This is the price of secret gen i
 			newPrice := secretNum % 10
-			// Delta between prev - new
-			delta := newPrice - currentPrice
-
-			deltas[i] = delta
-			if i >= 3 {
-				// key is a 'run' of 4 deltas to a value since we care about
-				// basically means this secret results in a gain/loss of this many bananas
-				key := [4]int(deltas[i-3 : i+1])
-				// We only want the first time we see such seq
-				if _, ok := changeSeq[key]; !ok {
-					changeSeq[key] = newPrice
-				}
+			// Delta between prev - new, shifted to 0-18
+			delta := newPrice - currentPrice + 9
+
+			// Shift the oldest change out and the newest one in
+			key = (key*DELTA_RANGE + delta) % SEQUENCE_COUNT
+			// We only want the first time we see such seq
+			if i >= 3 && seen[key] != stamp {
+				seen[key] = stamp
+				totals[key] += newPrice
 			}
 
 			currentPrice = newPrice
 		}
-		// For every sequence of 4 secrets/deltas
-		// Sum up the deltas into a top level map for getting the max later
-		// so this will give the max bananas for secret
-		// this will run 2000x times per secret
-		for changeSeq, bananas := range changeSeq {
-			secretBananaCounts[changeSeq] += bananas
+	}
+}
+
+// Buyers are independent of each other so each worker takes a shard of them
+// with its own totals, which are summed at the end to find the best sequence
+// ok is false when no sequence sells any bananas, e.g. there are no buyers
+func partTwo() (int, [4]int, bool) {
+	workers := min(runtime.NumCPU(), len(input))
+	shardTotals := make([][]int, workers)
+	var wg sync.WaitGroup
+	for w := range workers {
+		shardTotals[w] = make([]int, SEQUENCE_COUNT)
+		start := w * len(input) / workers
+		end := (w + 1) * len(input) / workers
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sellBananas(input[start:end], shardTotals[w])
+		}()
+	}
+	wg.Wait()
+
+	maxPossibleBanana := 0
+	bestKey := -1
+	for key := range SEQUENCE_COUNT {
+		bananas := 0
+		for _, totals := range shardTotals {
+			bananas += totals[key]
+		}
+		if bananas > maxPossibleBanana {
+			maxPossibleBanana = bananas
+			bestKey = key
 		}
 	}
 
-	// Simply find the secret with the maximum bananas (banana price)
-	for _, v := range secretBananaCounts {
-		if v > maxPossibleBanana {
-			maxPossibleBanana = v
+	if bestKey == -1 {
+		return 0, [4]int{}, false
+	}
+	return maxPossibleBanana, decodeSequence(bestKey), true
+}
+
+func parseSequence(s string) ([4]int, error) {
+	seq := [4]int{}
+	parts := strings.Split(s, ",")
+	if len(parts) != len(seq) {
+		return seq, fmt.Errorf("sequence %q must have %d changes", s, len(seq))
+	}
+	for i, part := range parts {
+		delta, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || delta < -9 || delta > 9 {
+			return seq, fmt.Errorf("invalid change %q in sequence %q", part, s)
 		}
+		seq[i] = delta
 	}
+	return seq, nil
+}
 
-	return maxPossibleBanana
+// Finds when the monkey would sell to each buyer for the given sequence, the
+// time is which secret (1 to 2000) the sale happens on
+func saleReport(seq [4]int) {
+	total := 0
+	for _, secret := range input {
+		prices, deltas := generateDeltas(secret)
+		sold := false
+		for i := 3; i < len(deltas); i++ {
+			if [4]int(deltas[i-3:i+1]) == seq {
+				fmt.Printf("%d: sells at %d for %d\n", secret, i+1, prices[i+1])
+				total += prices[i+1]
+				sold = true
+				break
+			}
+		}
+		if !sold {
+			fmt.Printf("%d: never sells\n", secret)
+		}
+	}
+	fmt.Println("Total:", total)
 }
 
 func main() {
+	flag.Parse()
 	defer timer()()
+	if *nthFlag >= 0 {
+		sum := 0
+		for _, secret := range input {
+			nth := nthSecret(secret, *nthFlag)
+			fmt.Printf("%d: %d\n", secret, nth)
+			sum += nth
+		}
+		fmt.Println("Sum:", sum)
+		return
+	}
+	if *sequenceFlag != "" {
+		seq, err := parseSequence(*sequenceFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		saleReport(seq)
+		return
+	}
 	fmt.Println("Part One:", partOne())
-	fmt.Println("Part Two:", partTwo())
+	bananas, seq, ok := partTwo()
+	fmt.Println("Part Two:", bananas)
+	if ok {
+		fmt.Println("Sequence:", seq)
+	} else {
+		fmt.Println("Sequence: none sell any bananas")
+	}
 
 }
@@ -2,8 +2,15 @@ package main
 
 import (
 	"bufio"
+	"encoding/xml"
+	"flag"
 	"fmt"
+	"io"
+	"iter"
+	"math/bits"
 	"os"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -14,22 +21,75 @@ type (
 		left  string
 		right string
 	}
-	tripletKey struct {
-		one   string
-		two   string
-		three string
-	}
 	node  map[string]struct{}
 	graph struct {
 		edges map[string]node
 	}
+	bitset []uint64
+	// Same graph as above but with each computer given an ID (by sorted name)
+	// so sets of computers can be bitsets rather than maps
+	indexedGraph struct {
+		names     []string
+		ids       map[string]int
+		neighbors []bitset
+	}
+	// What to draw attention to when exporting, the largest clique (the
+	// password) wins over the triangles from part one if they overlap
+	highlight struct {
+		cliqueNodes   node
+		cliqueEdges   map[connectionPair]struct{}
+		triangleNodes node
+		triangleEdges map[connectionPair]struct{}
+	}
+	graphML struct {
+		XMLName xml.Name     `xml:"graphml"`
+		Xmlns   string       `xml:"xmlns,attr"`
+		Keys    []graphMLKey `xml:"key"`
+		Graph   graphMLGraph `xml:"graph"`
+	}
+	graphMLKey struct {
+		ID       string `xml:"id,attr"`
+		For      string `xml:"for,attr"`
+		AttrName string `xml:"attr.name,attr"`
+		AttrType string `xml:"attr.type,attr"`
+	}
+	graphMLGraph struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	}
+	graphMLNode struct {
+		ID   string        `xml:"id,attr"`
+		Data []graphMLData `xml:"data"`
+	}
+	graphMLEdge struct {
+		Source string        `xml:"source,attr"`
+		Target string        `xml:"target,attr"`
+		Data   []graphMLData `xml:"data"`
+	}
+	graphMLData struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+)
+
+const (
+	CLIQUE_COLOUR   = "red"
+	TRIANGLE_COLOUR = "blue"
 )
 
 var (
 	pairs          = []connectionPair{}
 	network        = graph{edges: make(map[string]node)}
 	totalComputers = 0
-	foundCliques   = [][]string{}
+	sizeFlag       = flag.Int("k", 3, "size of the cliques counted in part one")
+	prefixFlag     = flag.String("prefix", "t", "part one only counts cliques with a computer starting with this")
+	regexFlag      = flag.String("regex", "", "part one only counts cliques with a computer matching this, replaces -prefix")
+	listFlag       = flag.Bool("list", false, "print every clique counted in part one")
+	dotFlag        = flag.String("dot", "", "write the network to this file as Graphviz DOT")
+	graphMLFlag    = flag.String("graphml", "", "write the network to this file as GraphML")
+	statsFlag      = flag.Bool("stats", false, "print the size, degree distribution and components of the network")
 )
 
 func init() {
@@ -44,11 +104,6 @@ func init() {
 			right: right,
 		})
 	}
-	foundCliques = network.findCliques()
-}
-
-func (k tripletKey) anyStartsWith(s string) bool {
-	return strings.HasPrefix(k.one, s) || strings.HasPrefix(k.two, s) || strings.HasPrefix(k.three, s)
 }
 
 func timer() func() {
@@ -58,56 +113,262 @@ func timer() func() {
 	}
 }
 
-func sortedKey(a, b, c string) tripletKey {
-	parts := []string{a, b, c}
-	sort.Strings(parts)
-	return tripletKey{parts[0], parts[1], parts[2]}
+func newBitset(size int) bitset {
+	return make(bitset, (size+63)/64)
 }
 
-func (g graph) findCliques() [][]string {
-	var cliques [][]string
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b bitset) clear(i int) {
+	b[i/64] &^= 1 << (i % 64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<(i%64)) != 0
+}
+
+func (b bitset) and(o bitset) bitset {
+	result := make(bitset, len(b))
+	for i := range b {
+		result[i] = b[i] & o[i]
+	}
+	return result
+}
+
+func (b bitset) andNot(o bitset) bitset {
+	result := make(bitset, len(b))
+	for i := range b {
+		result[i] = b[i] &^ o[i]
+	}
+	return result
+}
+
+func (b bitset) or(o bitset) bitset {
+	result := make(bitset, len(b))
+	for i := range b {
+		result[i] = b[i] | o[i]
+	}
+	return result
+}
+
+func (b bitset) count() int {
+	total := 0
+	for _, word := range b {
+		total += bits.OnesCount64(word)
+	}
+	return total
+}
+
+// Same as b.and(o).count() without building the intersection
+func (b bitset) andCount(o bitset) int {
+	total := 0
+	for i := range b {
+		total += bits.OnesCount64(b[i] & o[i])
+	}
+	return total
+}
+
+func (b bitset) empty() bool {
+	for _, word := range b {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Every set bit in ascending order
+func (b bitset) all() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i, word := range b {
+			for word != 0 {
+				if !yield(i*64 + bits.TrailingZeros64(word)) {
+					return
+				}
+				word &= word - 1
+			}
+		}
+	}
+}
+
+func (g graph) indexed() *indexedGraph {
+	names := make([]string, 0, len(g.edges))
+	for name := range g.edges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ig := &indexedGraph{
+		names:     names,
+		ids:       make(map[string]int, len(names)),
+		neighbors: make([]bitset, len(names)),
+	}
+	for id, name := range names {
+		ig.ids[name] = id
+	}
+	for id, name := range names {
+		ig.neighbors[id] = newBitset(len(names))
+		for neighbor := range g.edges[name] {
+			ig.neighbors[id].set(ig.ids[neighbor])
+		}
+	}
+	return ig
+}
 
-	currentClique := make(node)
-	candidateNodes := make(node)
-	excludedNodes := make(node)
-	for node := range g.edges {
-		candidateNodes[node] = struct{}{}
+func (ig *indexedGraph) namesOf(ids []int) []string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, ig.names[id])
 	}
+	sort.Strings(names)
+	return names
+}
 
-	bronKerbosch(currentClique, candidateNodes, excludedNodes, g, &cliques)
+// Repeatedly removes the computer with the fewest remaining connections. Starting
+// Bron–Kerbosch from each computer in this order and only looking at neighbors
+// later in the order keeps every top level candidate set no bigger than the
+// graph's degeneracy, which is tiny compared to the number of computers
+func (ig *indexedGraph) degeneracyOrder() []int {
+	size := len(ig.names)
+	degree := make([]int, size)
+	// Buckets can hold stale entries for computers whose degree has since
+	// dropped, they are skipped when popped instead of being searched for
+	buckets := [][]int{}
+	for id, neighbors := range ig.neighbors {
+		degree[id] = neighbors.count()
+		for len(buckets) <= degree[id] {
+			buckets = append(buckets, []int{})
+		}
+		buckets[degree[id]] = append(buckets[degree[id]], id)
+	}
+
+	removed := make([]bool, size)
+	order := make([]int, 0, size)
+	lowest := 0
+	for len(order) < size {
+		if len(buckets[lowest]) == 0 {
+			lowest++
+			continue
+		}
+		bucket := buckets[lowest]
+		id := bucket[len(bucket)-1]
+		buckets[lowest] = bucket[:len(bucket)-1]
+		if removed[id] || degree[id] != lowest {
+			continue
+		}
+
+		removed[id] = true
+		order = append(order, id)
+		for neighbor := range ig.neighbors[id].all() {
+			if !removed[neighbor] {
+				degree[neighbor]--
+				buckets[degree[neighbor]] = append(buckets[degree[neighbor]], neighbor)
+			}
+		}
+		// Removing a computer only lowers its neighbors by one
+		lowest = max(lowest-1, 0)
+	}
+	return order
+}
+
+// The pivot is the computer in candidates ⋃ excluded with the most connections
+// into candidates, any clique missing all of them could be grown with the
+// pivot so only the pivot and its non neighbors need to be tried
+func (ig *indexedGraph) choosePivot(candidateNodes, excludedNodes bitset) int {
+	pivot := -1
+	mostConnections := -1
+	for id := range candidateNodes.or(excludedNodes).all() {
+		if connections := candidateNodes.andCount(ig.neighbors[id]); connections > mostConnections {
+			pivot = id
+			mostConnections = connections
+		}
+	}
+	return pivot
+}
+
+// Runs fn on the starting call for each computer in degeneracy order, with
+// candidates being the neighbors that come later and excluded the earlier ones
+func (ig *indexedGraph) eachStart(fn func(id int, candidateNodes, excludedNodes bitset)) {
+	later := newBitset(len(ig.names))
+	for id := range ig.names {
+		later.set(id)
+	}
+	for _, id := range ig.degeneracyOrder() {
+		later.clear(id)
+		neighbors := ig.neighbors[id]
+		fn(id, neighbors.and(later), neighbors.andNot(later))
+	}
+}
+
+func (g graph) findCliques() [][]string {
+	var cliques [][]string
+
+	ig := g.indexed()
+	ig.eachStart(func(id int, candidateNodes, excludedNodes bitset) {
+		ig.bronKerbosch([]int{id}, candidateNodes, excludedNodes, func(clique []int) {
+			cliques = append(cliques, ig.namesOf(clique))
+		})
+	})
 
 	return cliques
 }
 
 // https://en.wikipedia.org/wiki/Bron%E2%80%93Kerbosch_algorithm
-// Implements the above to find the largest 'clique' which is the largest subgraph possible
-func bronKerbosch(currentClique, candidateNodes, excludedNodes node, graph graph, cliques *[][]string) {
-	if len(candidateNodes) == 0 && len(excludedNodes) == 0 {
-		// If we get to hear this clique has been fully explored so we
-		clique := []string{}
-		for node := range currentClique {
-			clique = append(clique, node)
-		}
-		*cliques = append(*cliques, clique)
+// Implements the above with pivoting to find every maximal 'clique', a subgraph
+// where everything is connected to everything else that can't be grown any more
+func (ig *indexedGraph) bronKerbosch(currentClique []int, candidateNodes, excludedNodes bitset, found func([]int)) {
+	if candidateNodes.empty() && excludedNodes.empty() {
+		// If we get to here this clique has been fully explored
+		found(currentClique)
 		return
 	}
 
-	for node := range candidateNodes {
-		newClique := copyMap(currentClique)
-		newClique[node] = struct{}{}
-
-		newCandidateNodes := mapIntersect(candidateNodes, graph.edges[node])
-		newExcludedNodes := mapIntersect(excludedNodes, graph.edges[node])
+	pivot := ig.choosePivot(candidateNodes, excludedNodes)
+	for node := range candidateNodes.andNot(ig.neighbors[pivot]).all() {
 		// As per the algorithm
 		// recursion with
 		// newClique = currentClique ⋃ node (union)
 		// newCandidateNodes = candidateNodes ⋂ (nodes children) (intersect)
 		// newExcludedNodes = excludedNodes ⋂ (nodes children) (intersect)
-		bronKerbosch(newClique, newCandidateNodes, newExcludedNodes, graph, cliques)
+		newClique := append(slices.Clip(currentClique), node)
+		ig.bronKerbosch(newClique, candidateNodes.and(ig.neighbors[node]), excludedNodes.and(ig.neighbors[node]), found)
 
 		// Remove from candidates and add it to exlcuded
-		delete(candidateNodes, node)
-		excludedNodes[node] = struct{}{}
+		candidateNodes.clear(node)
+		excludedNodes.set(node)
+	}
+}
+
+// Only looks for the single largest clique, excluded nodes only stop the
+// same clique being reported twice so they aren't needed here. Any branch
+// that can't beat the best clique so far, even if every candidate joined it,
+// is dropped
+func (ig *indexedGraph) maximumClique() []int {
+	best := []int{}
+	ig.eachStart(func(id int, candidateNodes, _ bitset) {
+		ig.growClique([]int{id}, candidateNodes, &best)
+	})
+	return best
+}
+
+func (ig *indexedGraph) growClique(currentClique []int, candidateNodes bitset, best *[]int) {
+	if candidateNodes.empty() {
+		if len(currentClique) > len(*best) {
+			*best = slices.Clone(currentClique)
+		}
+		return
+	}
+
+	pivot := ig.choosePivot(candidateNodes, candidateNodes)
+	for node := range candidateNodes.andNot(ig.neighbors[pivot]).all() {
+		if len(currentClique)+candidateNodes.count() <= len(*best) {
+			return
+		}
+		newClique := append(slices.Clip(currentClique), node)
+		ig.growClique(newClique, candidateNodes.and(ig.neighbors[node]), best)
+		candidateNodes.clear(node)
 	}
 }
 
@@ -124,95 +385,321 @@ func (g graph) addEdge(n, n2 string) {
 	g.edges[n2][n] = struct{}{}
 }
 
-// Deep copy of a map for recursion use
-func copyMap(m node) node {
-	copy := make(node)
-	for k := range m {
-		copy[k] = struct{}{}
+// Matches computer names against -regex if it's set, otherwise -prefix
+func newNameFilter(prefix, pattern string) (func(string) bool, error) {
+	if pattern == "" {
+		return func(name string) bool {
+			return strings.HasPrefix(name, prefix)
+		}, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
 	}
-	return copy
+	return re.MatchString, nil
 }
 
-// returns the elements in both maps
-func mapIntersect(m, m2 node) node {
-	result := make(node)
-	for k := range m {
-		if _, exists := m2[k]; exists {
-			result[k] = struct{}{}
+// Finds every clique of exactly size computers with at least one member
+// accepted by filter. Cliques are only ever grown with computers that have a
+// higher ID than the last one added, so each is built once in sorted order
+// and there's no need for a seen map to stop a,b,c and b,c,a both counting
+// If collect is false the cliques are only counted
+func (ig *indexedGraph) kCliques(size int, filter func(string) bool, collect bool) (int, [][]string) {
+	count := 0
+	cliques := [][]string{}
+	if size < 1 {
+		return count, cliques
+	}
+
+	matches := make([]bool, len(ig.names))
+	for id, name := range ig.names {
+		matches[id] = filter(name)
+	}
+
+	// later[id] is the neighbors of id with a higher ID
+	later := make([]bitset, len(ig.names))
+	higher := newBitset(len(ig.names))
+	for id := len(ig.names) - 1; id >= 0; id-- {
+		later[id] = ig.neighbors[id].and(higher)
+		higher.set(id)
+	}
+
+	var grow func(clique []int, candidateNodes bitset, matched bool)
+	grow = func(clique []int, candidateNodes bitset, matched bool) {
+		if len(clique) == size {
+			if matched {
+				count++
+				if collect {
+					cliques = append(cliques, ig.namesOf(clique))
+				}
+			}
+			return
+		}
+		// Not enough candidates left to fill the clique
+		if len(clique)+candidateNodes.count() < size {
+			return
+		}
+		for node := range candidateNodes.all() {
+			grow(append(slices.Clip(clique), node), candidateNodes.and(later[node]), matched || matches[node])
 		}
 	}
-	return result
+
+	for id := range ig.names {
+		grow([]int{id}, later[id], matches[id])
+	}
+
+	return count, cliques
 }
 
-// This is not a nice solution but since we only need to find a 'triplet' of nodes
-// then this is good enough
-// Checks every Node and its children
-// then checks the child of its children (grandchild)
-// then checks the children of the grandchildren (greatGrandChild)
-// if greatGrandChild == parent then we have looped back with length of 3 like so:
-// parent -> child -> grandchild -> parent (great grand child)
-// Could probably be rewritten to be more generic
-func partOne() int {
-	result := 0
-	tripletCache := make(map[tripletKey]struct{})
-	for parent, children := range network.edges {
+// Counts the cliques of -k computers (triangles by default) that have a
+// computer matching the filter, which for the puzzle is any starting with t
+func partOne() (int, [][]string) {
+	filter, err := newNameFilter(*prefixFlag, *regexFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return network.indexed().kCliques(*sizeFlag, filter, *listFlag)
+}
+
+// This is slightly different as it's asking for the largest connected graph, there is a known algorithm for this
+// https://en.wikipedia.org/wiki/Bron%E2%80%93Kerbosch_algorithm
+// We only care about the biggest clique so there's no need to find all of them
+func partTwo() string {
+	ig := network.indexed()
+	return strings.Join(ig.namesOf(ig.maximumClique()), ",")
+}
+
+// Always stored with the names in order so a-b and b-a are the same link
+func newConnectionPair(a, b string) connectionPair {
+	if b < a {
+		a, b = b, a
+	}
+	return connectionPair{left: a, right: b}
+}
+
+// Every link once, sorted so exports come out the same each run
+func (g graph) sortedPairs() []connectionPair {
+	result := []connectionPair{}
+	for n, children := range g.edges {
 		for child := range children {
-			// prevents loops since N <--> A is undirected you can get there from either node
-			// so they both know about each other!
-			if child == parent {
-				continue
+			if n < child {
+				result = append(result, connectionPair{left: n, right: child})
 			}
-			for grandChild := range network.edges[child] {
-				for greatGrandChild := range network.edges[grandChild] {
-					if greatGrandChild == parent {
-						// Uses a hash key based on the sorted order because
-						// a,b,c
-						// a,c,b
-						// b,c,a
-						// etc are all the same triangle of nodes and we don't want to double count. Sorted key for each would be
-						// a,b,c => a,b,c
-						// a,c,b => a,b,c
-						// b,c,a => a,b,c
-						// Not super effcient doing this, there's likely a better way but it works for now,
-						triplet := sortedKey(parent, child, grandChild)
-						if _, seen := tripletCache[triplet]; !seen {
-							tripletCache[triplet] = struct{}{}
-						}
-					}
-				}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].left != result[j].left {
+			return result[i].left < result[j].left
+		}
+		return result[i].right < result[j].right
+	})
+	return result
+}
+
+func newHighlight(largest []string, triangles [][]string) highlight {
+	h := highlight{
+		cliqueNodes:   make(node),
+		cliqueEdges:   make(map[connectionPair]struct{}),
+		triangleNodes: make(node),
+		triangleEdges: make(map[connectionPair]struct{}),
+	}
+	mark := func(members []string, nodes node, edges map[connectionPair]struct{}) {
+		for i, n := range members {
+			nodes[n] = struct{}{}
+			for _, n2 := range members[i+1:] {
+				edges[newConnectionPair(n, n2)] = struct{}{}
 			}
 		}
 	}
-	for k := range tripletCache {
-		if k.anyStartsWith("t") {
-			result++
+	for _, triangle := range triangles {
+		mark(triangle, h.triangleNodes, h.triangleEdges)
+	}
+	mark(largest, h.cliqueNodes, h.cliqueEdges)
+	return h
+}
+
+// Colour for a computer, empty if it isn't highlighted
+func (h highlight) nodeColour(n string) string {
+	if _, ok := h.cliqueNodes[n]; ok {
+		return CLIQUE_COLOUR
+	}
+	if _, ok := h.triangleNodes[n]; ok {
+		return TRIANGLE_COLOUR
+	}
+	return ""
+}
+
+func (h highlight) edgeColour(pair connectionPair) string {
+	if _, ok := h.cliqueEdges[pair]; ok {
+		return CLIQUE_COLOUR
+	}
+	if _, ok := h.triangleEdges[pair]; ok {
+		return TRIANGLE_COLOUR
+	}
+	return ""
+}
+
+func (g graph) writeDOT(w io.Writer, h highlight) error {
+	var sb strings.Builder
+	sb.WriteString("graph lan {\n")
+	sb.WriteString("\tnode [shape=circle];\n")
+	names := g.indexed().names
+	for _, n := range names {
+		if colour := h.nodeColour(n); colour != "" {
+			fmt.Fprintf(&sb, "\t%q [style=filled, fillcolor=%s, fontcolor=white];\n", n, colour)
+			continue
+		}
+		fmt.Fprintf(&sb, "\t%q;\n", n)
+	}
+	for _, pair := range g.sortedPairs() {
+		if colour := h.edgeColour(pair); colour != "" {
+			fmt.Fprintf(&sb, "\t%q -- %q [color=%s, penwidth=2];\n", pair.left, pair.right, colour)
+			continue
 		}
+		fmt.Fprintf(&sb, "\t%q -- %q;\n", pair.left, pair.right)
 	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
 
-	return result
+// The colour is stored as data on each node and edge, most GraphML viewers
+// can then be told to style by it
+func (g graph) writeGraphML(w io.Writer, h highlight) error {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "ncolour", For: "node", AttrName: "colour", AttrType: "string"},
+			{ID: "ecolour", For: "edge", AttrName: "colour", AttrType: "string"},
+		},
+		Graph: graphMLGraph{ID: "lan", EdgeDefault: "undirected"},
+	}
+	for _, n := range g.indexed().names {
+		graphNode := graphMLNode{ID: n}
+		if colour := h.nodeColour(n); colour != "" {
+			graphNode.Data = []graphMLData{{Key: "ncolour", Value: colour}}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphNode)
+	}
+	for _, pair := range g.sortedPairs() {
+		graphEdge := graphMLEdge{Source: pair.left, Target: pair.right}
+		if colour := h.edgeColour(pair); colour != "" {
+			graphEdge.Data = []graphMLData{{Key: "ecolour", Value: colour}}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, graphEdge)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
 }
 
-// This is slightly different as it's asking for the largest connected graph, there is a known algorithm for this
-// https://en.wikipedia.org/wiki/Bron%E2%80%93Kerbosch_algorithm
-// We found all the cliques during init so now its just a case of processing them
-func partTwo() string {
-	largestNetwork := []string{}
-	largestNetworkSize := 0
+func writeFile(filename string, write func(io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	for _, clique := range foundCliques {
-		if l := len(clique); l > largestNetworkSize {
-			largestNetwork = clique
-			largestNetworkSize = l
+// Sizes of each group of computers that can reach each other, largest first
+func (ig *indexedGraph) componentSizes() []int {
+	sizes := []int{}
+	seen := make([]bool, len(ig.names))
+	for id := range ig.names {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue := []int{id}
+		size := 0
+		for len(queue) > 0 {
+			curr := queue[0]
+			queue = queue[1:]
+			size++
+			for neighbor := range ig.neighbors[curr].all() {
+				if !seen[neighbor] {
+					seen[neighbor] = true
+					queue = append(queue, neighbor)
+				}
+			}
 		}
+		sizes = append(sizes, size)
 	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	return sizes
+}
+
+func printStats(g graph) {
+	ig := g.indexed()
+	fmt.Println("Computers:", len(ig.names))
+	fmt.Println("Connections:", len(g.sortedPairs()))
 
-	sort.Strings(largestNetwork)
-	return strings.Join(largestNetwork, ",")
+	degrees := make(map[int]int)
+	for _, neighbors := range ig.neighbors {
+		degrees[neighbors.count()]++
+	}
+	keys := []int{}
+	for degree := range degrees {
+		keys = append(keys, degree)
+	}
+	sort.Ints(keys)
+	for _, degree := range keys {
+		fmt.Printf("Degree %d: %d computers\n", degree, degrees[degree])
+	}
+
+	sizes := ig.componentSizes()
+	fmt.Println("Components:", len(sizes), sizes)
 }
 
 func main() {
+	flag.Parse()
 	defer timer()()
-	fmt.Println("Part One:", partOne())
-	fmt.Println("Part Two:", partTwo())
+	count, cliques := partOne()
+	for _, clique := range cliques {
+		fmt.Println(strings.Join(clique, ","))
+	}
+	fmt.Println("Part One:", count)
+	password := partTwo()
+	fmt.Println("Part Two:", password)
+
+	if *statsFlag {
+		printStats(network)
+	}
+	if *dotFlag != "" || *graphMLFlag != "" {
+		// Triangles are always highlighted even if part one was asked for a
+		// different size, the filter is kept though
+		filter, _ := newNameFilter(*prefixFlag, *regexFlag)
+		_, triangles := network.indexed().kCliques(3, filter, true)
+		largest := []string{}
+		if password != "" {
+			largest = strings.Split(password, ",")
+		}
+		h := newHighlight(largest, triangles)
+		if *dotFlag != "" {
+			if err := writeFile(*dotFlag, func(w io.Writer) error { return network.writeDOT(w, h) }); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+		if *graphMLFlag != "" {
+			if err := writeFile(*graphMLFlag, func(w io.Writer) error { return network.writeGraphML(w, h) }); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		}
+	}
 
 }
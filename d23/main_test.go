@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// Random graph where every computer is linked to degree others, with a clique
+// of cliqueSize hidden in it like the puzzle input
+func randomGraph(size, degree, cliqueSize int) graph {
+	rng := rand.New(rand.NewPCG(2024, 23))
+	g := graph{edges: make(map[string]node)}
+	name := func(id int) string {
+		return fmt.Sprintf("c%d", id)
+	}
+	for id := range size {
+		for range degree / 2 {
+			if other := rng.IntN(size); other != id {
+				g.addEdge(name(id), name(other))
+			}
+		}
+	}
+	clique := rng.Perm(size)[:cliqueSize]
+	for i, id := range clique {
+		for _, other := range clique[i+1:] {
+			g.addEdge(name(id), name(other))
+		}
+	}
+	return g
+}
+
+var benchmarkSizes = []int{1000, 5000, 10000, 20000}
+
+func BenchmarkFindCliques(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		g := randomGraph(size, 16, 14)
+		b.Run(fmt.Sprintf("%d computers", size), func(b *testing.B) {
+			for b.Loop() {
+				g.findCliques()
+			}
+		})
+	}
+}
+
+func BenchmarkMaximumClique(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		g := randomGraph(size, 16, 14)
+		b.Run(fmt.Sprintf("%d computers", size), func(b *testing.B) {
+			for b.Loop() {
+				if largest := g.indexed().maximumClique(); len(largest) < 14 {
+					b.Fatalf("largest clique has %d computers, want at least 14", len(largest))
+				}
+			}
+		})
+	}
+}
@@ -2,150 +2,140 @@ package main
 
 import (
 	"bufio"
+	"container/heap"
 	"fmt"
 	"os"
 	"time"
 )
 
-type block struct {
-	blockId    int
-	startIndex int
-	endIndex   int
-	size       int
-	free       bool
-}
+// Start positions of free spans that are all the same length, smallest first
+type spanHeap []int
+
+const (
+	// Each digit of the disk map is a single length so no span is longer
+	MAX_SPAN_LENGTH = 9
+)
 
-var files = make(map[int]*block)
-var blanks = []*block{}
-var largestFileId = -1
-var totalLineSize = 0
+// The disk map as it was read, even indexes are file lengths and odd indexes
+// the free space after them so file n is diskMap[2n]
+var diskMap = []int{}
 
 func init() {
 	scanner := bufio.NewScanner(os.Stdin)
-	fileId := 0
-	processedBlocks := 0
-	currentIndex := 0
 	for scanner.Scan() {
 		data := scanner.Text()
 		for _, r := range data {
-			runeAsInt := int(r - '0')
-			totalLineSize += runeAsInt
-			if processedBlocks%2 == 0 {
-				// even means were on a file block...
-				files[fileId] = &block{fileId, currentIndex, currentIndex + runeAsInt - 1, runeAsInt, false}
-				largestFileId = fileId
-				fileId++
-			} else {
-				// odds are blank
-				blanks = append(blanks, &block{-1, currentIndex, currentIndex + runeAsInt - 1, runeAsInt, true})
-			}
-			currentIndex += runeAsInt
-			processedBlocks++
+			diskMap = append(diskMap, int(r-'0'))
 		}
 	}
 }
 
-func (b *block) checkSum() int {
-	result := 0
-	for i := 0; i < b.size; i++ {
-		result += b.blockId * (b.startIndex + i)
-	}
-	return result
+func (h spanHeap) Len() int           { return len(h) }
+func (h spanHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h spanHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *spanHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *spanHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
 }
 
-func getNextSwappable(currentSwappable int, fileSystem []int) int {
-	for i := currentSwappable - 1; i >= 0; i-- {
-		if fileSystem[i] != -1 {
-			return i
-		}
-	}
-	return -1
+// The checksum of a file with this id filling length blocks from start,
+// id * (start + start+1 + ... + start+length-1) without the loop
+func checkSum(id, start, length int) int {
+	return id * (start*length + length*(length-1)/2)
 }
 
-func checkSum(fileSystem []int) int {
+// Never expands the disk into blocks, left walks the map from the front and
+// right is the last file that still has blocks to move. Files left walks
+// over stay where they are and free space it reaches gets filled from the
+// back of right until it runs out of free space or catches up with right
+func partOne(diskMap []int) int {
+	if len(diskMap) == 0 {
+		return 0
+	}
 	result := 0
-	for i, v := range fileSystem {
-		if v != -1 {
-			result += i * v
+	right := (len(diskMap) - 1) / 2
+	remaining := diskMap[2*right]
+	position := 0
+
+	for left := 0; left < len(diskMap); left++ {
+		if left%2 == 0 {
+			id := left / 2
+			if id > right {
+				break
+			}
+			length := diskMap[left]
+			if id == right {
+				// Some of the last file may have already been moved
+				length = remaining
+			}
+			result += checkSum(id, position, length)
+			position += length
+			continue
 		}
-	}
-	return result
-}
 
-func partOne() int {
-	// bit hacky but saves messing up part 2s data! could be refactored for sure, horrendous time complexity for sure
-	// fmt.Println("Line size", totalLineSize)
-
-	result := make([]int, totalLineSize, totalLineSize)
-	blankIndexes := []int{}
-
-	for _, blank := range blanks {
-		for i := 0; i < blank.size; i++ {
-			result[blank.startIndex+i] = -1
-			blankIndexes = append(blankIndexes, blank.startIndex+i)
+		free := diskMap[left]
+		for free > 0 && right > left/2 {
+			moved := min(free, remaining)
+			result += checkSum(right, position, moved)
+			position += moved
+			free -= moved
+			remaining -= moved
+			if remaining == 0 {
+				right--
+				remaining = diskMap[2*right]
+			}
 		}
 	}
+	return result
+}
 
-	for fileId, block := range files {
-		for i := 0; i < block.size; i++ {
-			result[block.startIndex+i] = fileId
+// free[n] holds the start of every free span n blocks long. For each file,
+// highest id first, the leftmost span it fits is the smallest top of the
+// heaps for its length and longer. Whatever it doesn't use goes back into
+// the heap for the shorter length. The space a file leaves behind never
+// needs adding as every file still to move is to the left of it
+func partTwo(diskMap []int) int {
+	free := [MAX_SPAN_LENGTH + 1]spanHeap{}
+	starts := make([]int, len(diskMap))
+	position := 0
+	for i, length := range diskMap {
+		starts[i] = position
+		if i%2 == 1 && length > 0 {
+			free[length] = append(free[length], position)
 		}
+		position += length
 	}
+	// Spans were added left to right so every heap is already in order
 
-	nextSwappable := getNextSwappable(len(result), result)
-
-	for _, blankIndex := range blankIndexes {
-		if blankIndex > nextSwappable {
-			break
-		}
-		result[blankIndex], result[nextSwappable] = result[nextSwappable], result[blankIndex]
-		nextSwappable = getNextSwappable(nextSwappable, result)
+	// Maps that end on free space have their last file one before the end
+	last := len(diskMap) - 1
+	if last%2 == 1 {
+		last--
 	}
 
-	return checkSum(result)
-}
-
-func partTwo() int {
 	result := 0
-	currentFileId := largestFileId
-
-	for currentFileId > -1 {
-		fileBlock := files[currentFileId]
-		for _, blank := range blanks {
-			if !blank.free {
-				continue
-			}
-			if blank.startIndex >= fileBlock.startIndex {
-				// We've gone too far as files can only move left
-				break
+	for i := last; i >= 0; i -= 2 {
+		id, length, start := i/2, diskMap[i], starts[i]
+
+		best := -1
+		for spanLength := length; spanLength <= MAX_SPAN_LENGTH; spanLength++ {
+			if len(free[spanLength]) > 0 && free[spanLength][0] < start &&
+				(best == -1 || free[spanLength][0] < free[best][0]) {
+				best = spanLength
 			}
-			if fileBlock.size <= blank.size {
-				// fmt.Printf("Can Move file id %d into blank %+v\n", currentFileId, blank)
-				// Now we can move file...
-				fileBlock.startIndex = blank.startIndex
-				fileBlock.endIndex = blank.startIndex + fileBlock.size - 1
-				blank.size = blank.size - fileBlock.size
-				// Not -1 here because we want the next free start place not the end of the old blank
-				// e.g consider ..1
-				// if blank is current;y
-				// start 0 end 1 size 2
-				// and we move 1 into it (with size 1)
-				// the blank is now
-				// start 0 + 1, size 2-1 (blank.size - file.size)
-				// Doing minus -1 before incrementing index would mean start would be 0, which is where we just inserted
-				// essentially this means we set startIndex to lastInserted +!
-				blank.startIndex = blank.startIndex + fileBlock.size
-				if blank.size == 0 {
-					blank.free = false
-				}
-				break
+		}
+		if best != -1 && length > 0 {
+			spanStart := heap.Pop(&free[best]).(int)
+			start = spanStart
+			if best > length {
+				heap.Push(&free[best-length], spanStart+length)
 			}
 		}
-		currentFileId--
-	}
-
-	for _, file := range files {
-		result += file.checkSum()
+		result += checkSum(id, start, length)
 	}
 	return result
 }
@@ -159,6 +149,6 @@ func timer() func() {
 
 func main() {
 	defer timer()()
-	fmt.Println("Part One:", partOne())
-	fmt.Println("Part Two:", partTwo())
+	fmt.Println("Part One:", partOne(diskMap))
+	fmt.Println("Part Two:", partTwo(diskMap))
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// A random disk map with digits digits, files are never empty but free
+// space can be
+func randomDiskMap(digits int) []int {
+	rng := rand.New(rand.NewPCG(2024, 9))
+	result := make([]int, digits)
+	for i := range result {
+		if i%2 == 0 {
+			result[i] = 1 + rng.IntN(9)
+		} else {
+			result[i] = rng.IntN(10)
+		}
+	}
+	return result
+}
+
+func TestSample(t *testing.T) {
+	disk := []int{2, 3, 3, 3, 1, 3, 3, 1, 2, 1, 4, 1, 4, 1, 3, 1, 4, 0, 2}
+	if got := partOne(disk); got != 1928 {
+		t.Errorf("partOne() = %d, want 1928", got)
+	}
+	if got := partTwo(disk); got != 2858 {
+		t.Errorf("partTwo() = %d, want 2858", got)
+	}
+}
+
+func BenchmarkPartOne(b *testing.B) {
+	disk := randomDiskMap(100_000)
+	for b.Loop() {
+		partOne(disk)
+	}
+}
+
+func BenchmarkPartTwo(b *testing.B) {
+	disk := randomDiskMap(100_000)
+	for b.Loop() {
+		partTwo(disk)
+	}
+}